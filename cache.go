@@ -0,0 +1,382 @@
+package xrouter
+
+import (
+	"bufio"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachePolicy describes the Cache-Control header for a response and how long the response may be cached by the
+// Cache middleware.
+type CachePolicy struct {
+	MaxAge         time.Duration
+	Public         bool
+	Private        bool
+	NoCache        bool
+	NoStore        bool
+	MustRevalidate bool
+	Immutable      bool
+}
+
+// String returns the Cache-Control header value for the policy.
+func (p CachePolicy) String() string {
+	var directives []string
+	switch {
+	case p.Public:
+		directives = append(directives, "public")
+	case p.Private:
+		directives = append(directives, "private")
+	}
+	if p.NoCache {
+		directives = append(directives, "no-cache")
+	}
+	if p.NoStore {
+		directives = append(directives, "no-store")
+	}
+	if p.MaxAge > 0 {
+		directives = append(directives, "max-age="+strconv.Itoa(int(p.MaxAge/time.Second)))
+	}
+	if p.MustRevalidate {
+		directives = append(directives, "must-revalidate")
+	}
+	if p.Immutable {
+		directives = append(directives, "immutable")
+	}
+	return strings.Join(directives, ", ")
+}
+
+// storable returns true if responses to the request under the policy may be kept in a shared cache. Responses to
+// requests with credentials, an Authorization or Cookie header, are only shared when the policy is explicitly public.
+func (p CachePolicy) storable(r *http.Request) bool {
+	if (r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "") && !p.Public {
+		return false
+	}
+	return p.MaxAge > 0 && !p.NoStore && !p.Private && !p.NoCache
+}
+
+// CacheControl overrides the cache policy of the Cache middleware for a single route.
+func CacheControl(policy CachePolicy) RouteOption {
+	return func(info *RouteInfo) {
+		info.CachePolicy = &policy
+	}
+}
+
+// CachedResponse is a response held by a CacheStore.
+type CachedResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// CacheStore stores complete responses for the Cache middleware.
+type CacheStore interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, resp *CachedResponse, ttl time.Duration)
+}
+
+// CacheOptions configures the Cache middleware.
+type CacheOptions struct {
+	// Policy is the default cache policy. Routes can override it with the CacheControl option.
+	Policy CachePolicy
+
+	// WeakETags makes generated ETags weak validators.
+	WeakETags bool
+
+	// CacheResponses enables caching of complete responses in Store when the policy allows it.
+	CacheResponses bool
+
+	// Store holds cached responses. Defaults to an LRU store holding 1000 responses.
+	Store CacheStore
+
+	// VaryHeaders lists request headers which are included in the cache key, such as Accept or Accept-Language.
+	// Responses which vary on other headers, including encoded responses unless Accept-Encoding is listed, are not
+	// stored.
+	VaryHeaders []string
+}
+
+// CacheHeader is the response header which reports whether a response was served from the cache. LogHandler adds its
+// value to access logs.
+const CacheHeader = "X-Cache"
+
+// Cache returns middleware which adds ETags to successful GET and HEAD responses, answers conditional requests with
+// 304 Not Modified and optionally caches complete responses. Responses which are flushed while being written are
+// streamed unchanged.
+func Cache(opts CacheOptions) func(http.Handler) http.Handler {
+	if opts.CacheResponses && opts.Store == nil {
+		opts.Store = NewLRUStore(1000)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "GET" && r.Method != "HEAD" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			for _, name := range opts.VaryHeaders {
+				w.Header().Add("Vary", name)
+			}
+
+			policy := opts.Policy
+			if info, ok := RouteFromContext(r.Context()); ok && info.CachePolicy != nil {
+				policy = *info.CachePolicy
+			}
+
+			var key string
+			caching := opts.CacheResponses && policy.storable(r)
+			if caching {
+				key = cacheKey(r, opts.VaryHeaders)
+				if resp, ok := opts.Store.Get(key); ok && !strings.Contains(r.Header.Get("Cache-Control"), "no-cache") {
+					w.Header().Set(CacheHeader, "HIT")
+					writeCached(w, r, resp)
+					return
+				}
+				w.Header().Set(CacheHeader, "MISS")
+			}
+
+			cw := &cacheWriter{ResponseWriter: w}
+			next.ServeHTTP(cw, r)
+			if cw.streaming {
+				return
+			}
+
+			h := w.Header()
+			if cw.status == 0 {
+				cw.status = http.StatusOK
+			}
+			if cw.status == http.StatusOK {
+				if h.Get("ETag") == "" {
+					h.Set("ETag", computeETag(cw.body, opts.WeakETags))
+				}
+				if h.Get("Cache-Control") == "" && policy != (CachePolicy{}) {
+					h.Set("Cache-Control", policy.String())
+				}
+				// HEAD responses may be served from the entry of a GET request but never fill it, as their body may be
+				// empty.
+				if caching && r.Method == "GET" && h.Get("Set-Cookie") == "" && keyedBy(h, opts.VaryHeaders) {
+					h.Del(CacheHeader)
+					opts.Store.Set(key, &CachedResponse{cw.status, h.Clone(), cw.body}, policy.MaxAge)
+					h.Set(CacheHeader, "MISS")
+				}
+			}
+			writeCached(w, r, &CachedResponse{cw.status, nil, cw.body})
+		})
+	}
+}
+
+// cacheKey identifies a response by method, route, URL and the selected request headers. HEAD requests share the
+// entry of the corresponding GET request.
+func cacheKey(r *http.Request, vary []string) string {
+	var b strings.Builder
+	b.WriteString("GET ")
+	if info, ok := RouteFromContext(r.Context()); ok {
+		b.WriteString(info.Path)
+		b.WriteString(" ")
+	}
+	b.WriteString(r.URL.RequestURI())
+	for _, name := range vary {
+		b.WriteString("\n")
+		b.WriteString(name)
+		b.WriteString(": ")
+		b.WriteString(url.QueryEscape(r.Header.Get(name)))
+	}
+	return b.String()
+}
+
+// keyedBy reports whether the cache key, made of the vary headers, identifies the response: every header named by its
+// Vary header must be part of the key, and so must Accept-Encoding if the response is encoded.
+func keyedBy(h http.Header, vary []string) bool {
+	covered := func(name string) bool {
+		return slices.ContainsFunc(vary, func(v string) bool { return strings.EqualFold(v, name) })
+	}
+	if h.Get("Content-Encoding") != "" && !covered("Accept-Encoding") {
+		return false
+	}
+	for _, name := range headerTokens(h, "Vary") {
+		if name == "*" || !covered(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// writeCached writes a response, answering with 304 Not Modified if the request's validators match. Headers from the
+// cached response, if any, are copied first.
+func writeCached(w http.ResponseWriter, r *http.Request, resp *CachedResponse) {
+	h := w.Header()
+	for k, v := range resp.Header {
+		if k != CacheHeader {
+			h[k] = append([]string(nil), v...)
+		}
+	}
+	if resp.Status == http.StatusOK && notModified(r, h) {
+		h.Del("Content-Type")
+		h.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(resp.Status)
+	if r.Method != "HEAD" {
+		w.Write(resp.Body)
+	}
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when no entity tags were sent, against the response headers.
+func notModified(r *http.Request, h http.Header) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag := strings.TrimPrefix(h.Get("ETag"), "W/")
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(h.Get("Last-Modified"))
+	return err == nil && !modified.After(ims)
+}
+
+// computeETag returns an entity tag derived from the body.
+func computeETag(body []byte, weak bool) string {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	if weak {
+		return "W/" + etag
+	}
+	return etag
+}
+
+// cacheWriter buffers a response so that its ETag can be computed. Flushing switches to streaming the response.
+type cacheWriter struct {
+	http.ResponseWriter
+	status    int
+	body      []byte
+	streaming bool
+}
+
+func (c *cacheWriter) WriteHeader(code int) {
+	if c.streaming {
+		c.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if c.status == 0 {
+		c.status = code
+	}
+}
+
+func (c *cacheWriter) Write(p []byte) (int, error) {
+	if c.streaming {
+		return c.ResponseWriter.Write(p)
+	}
+	c.body = append(c.body, p...)
+	return len(p), nil
+}
+
+// Flush writes the buffered response and streams everything written afterwards.
+func (c *cacheWriter) Flush() {
+	if !c.streaming {
+		c.streaming = true
+		c.ResponseWriter.Header().Del(CacheHeader)
+		if c.status == 0 {
+			c.status = http.StatusOK
+		}
+		c.ResponseWriter.WriteHeader(c.status)
+		c.ResponseWriter.Write(c.body)
+		c.body = nil
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (c *cacheWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	c.streaming = true
+	return c.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// Unwrap returns the underlying ResponseWriter for use with http.ResponseController.
+func (c *cacheWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// LRUStore is an in-memory CacheStore which evicts the least recently used response when it is full.
+type LRUStore struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List
+}
+
+type lruEntry struct {
+	key     string
+	resp    *CachedResponse
+	expires time.Time
+}
+
+// NewLRUStore creates an LRUStore which holds up to capacity responses.
+func NewLRUStore(capacity int) *LRUStore {
+	return &LRUStore{capacity: capacity, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+// Get returns an unexpired response.
+func (s *LRUStore) Get(key string) (*CachedResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*lruEntry)
+	if time.Now().After(entry.expires) {
+		s.order.Remove(el)
+		delete(s.entries, key)
+		return nil, false
+	}
+	s.order.MoveToFront(el)
+	return entry.resp, true
+}
+
+// Set stores a response for the given duration.
+func (s *LRUStore) Set(key string, resp *CachedResponse, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := &lruEntry{key, resp, time.Now().Add(ttl)}
+	if el, ok := s.entries[key]; ok {
+		el.Value = entry
+		s.order.MoveToFront(el)
+		return
+	}
+	s.entries[key] = s.order.PushFront(entry)
+	for s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// Len returns the number of stored responses.
+func (s *LRUStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
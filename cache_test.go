@@ -0,0 +1,187 @@
+package xrouter
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newCacheRouter(calls *int) Router {
+	r := New()
+	r.Use(Cache(CacheOptions{
+		Policy:         CachePolicy{Public: true, MaxAge: time.Minute},
+		CacheResponses: true,
+		VaryHeaders:    []string{"Accept-Language"},
+	}))
+	greeting := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		*calls++
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(ResponseBody + " " + r.Header.Get("Accept-Language")))
+	}
+	r.GET("/greeting", greeting)
+	r.HEAD("/greeting", greeting)
+	r.GET("/private", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		*calls++
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		w.Write([]byte(ResponseBody))
+	}, CacheControl(CachePolicy{Private: true, NoCache: true}))
+	r.HEAD("/empty", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		*calls++
+		w.WriteHeader(http.StatusOK)
+	})
+	r.GET("/empty", greeting)
+	r.GET("/account", greeting, CacheControl(CachePolicy{MaxAge: time.Minute}))
+	r.POST("/greeting", PostTest)
+	return r
+}
+
+func TestCachePolicyString(t *testing.T) {
+	assert.Equal(t, "public, max-age=60", CachePolicy{Public: true, MaxAge: time.Minute}.String())
+	assert.Equal(t, "private, no-cache, must-revalidate", CachePolicy{Private: true, NoCache: true, MustRevalidate: true}.String())
+	assert.Equal(t, "no-store", CachePolicy{NoStore: true}.String())
+}
+
+func TestCacheETag(t *testing.T) {
+	calls := 0
+	r := newCacheRouter(&calls)
+
+	w := send(r, "GET", "/greeting", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+	assert.Equal(t, "public, max-age=60", w.Header().Get("Cache-Control"))
+	assert.Equal(t, "MISS", w.Header().Get(CacheHeader))
+
+	w = send(r, "GET", "/greeting", nil, "If-None-Match", etag)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, 0, w.Body.Len())
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+	assert.Equal(t, "HIT", w.Header().Get(CacheHeader))
+	assert.Equal(t, 1, calls)
+}
+
+func TestCacheResponses(t *testing.T) {
+	calls := 0
+	r := newCacheRouter(&calls)
+
+	w := send(r, "GET", "/greeting", nil, "Accept-Language", "en")
+	assert.Equal(t, ResponseBody+" en", w.Body.String())
+	w = send(r, "GET", "/greeting", nil, "Accept-Language", "en")
+	assert.Equal(t, ResponseBody+" en", w.Body.String())
+	assert.Equal(t, "HIT", w.Header().Get(CacheHeader))
+	assert.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+	assert.Equal(t, 1, calls)
+
+	w = send(r, "GET", "/greeting", nil, "Accept-Language", "fr")
+	assert.Equal(t, ResponseBody+" fr", w.Body.String())
+	assert.Equal(t, "MISS", w.Header().Get(CacheHeader))
+	assert.Equal(t, 2, calls)
+
+	w = send(r, "HEAD", "/greeting", nil, "Accept-Language", "fr")
+	assert.Equal(t, "HIT", w.Header().Get(CacheHeader))
+	assert.Equal(t, 0, w.Body.Len())
+	assert.Equal(t, 2, calls)
+
+	w = send(r, "POST", "/greeting", nil)
+	assert.Equal(t, "", w.Header().Get("ETag"))
+}
+
+func TestCacheHeadDoesNotStore(t *testing.T) {
+	calls := 0
+	r := newCacheRouter(&calls)
+
+	w := send(r, "HEAD", "/empty", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = send(r, "GET", "/empty", nil)
+	assert.Equal(t, "MISS", w.Header().Get(CacheHeader))
+	assert.Equal(t, ResponseBody+" ", w.Body.String())
+	assert.Equal(t, 2, calls)
+}
+
+func TestCacheSkipsAuthorizedRequests(t *testing.T) {
+	calls := 0
+	r := newCacheRouter(&calls)
+	alice := []string{"Authorization", "Bearer alice"}
+
+	w := send(r, "GET", "/account", nil, alice...)
+	assert.Equal(t, "", w.Header().Get(CacheHeader))
+	w = send(r, "GET", "/account", nil, "Authorization", "Bearer bob")
+	assert.Equal(t, "", w.Header().Get(CacheHeader))
+	assert.Equal(t, 2, calls)
+
+	// An unauthorized request fills the cache, but authorized requests still skip it.
+	send(r, "GET", "/account", nil)
+	assert.Equal(t, "HIT", send(r, "GET", "/account", nil).Header().Get(CacheHeader))
+	send(r, "GET", "/account", nil, alice...)
+	assert.Equal(t, 4, calls)
+
+	// Explicitly public policies are shared between users.
+	send(r, "GET", "/greeting", nil, alice...)
+	w = send(r, "GET", "/greeting", nil, "Authorization", "Bearer bob")
+	assert.Equal(t, "HIT", w.Header().Get(CacheHeader))
+}
+
+func TestCacheSkipsCookieRequests(t *testing.T) {
+	calls := 0
+	r := newCacheRouter(&calls)
+	send(r, "GET", "/account", nil, "Cookie", "session=alice")
+	w := send(r, "GET", "/account", nil, "Cookie", "session=bob")
+	assert.Equal(t, "", w.Header().Get(CacheHeader))
+	assert.Equal(t, 2, calls)
+}
+
+func TestCacheRespectsResponseVary(t *testing.T) {
+	r := New()
+	r.Use(Cache(CacheOptions{Policy: CachePolicy{Public: true, MaxAge: time.Minute}, CacheResponses: true}))
+	r.Use(Compress(CompressOptions{MinSize: 1}))
+	r.GET("/greeting", GetTest)
+	r.GET("/theme", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Vary", "Sec-CH-Prefers-Color-Scheme")
+		w.Write([]byte(r.Header.Get("Sec-CH-Prefers-Color-Scheme")))
+	})
+
+	// A compressed response is not served to a client which does not accept it.
+	send(r, "GET", "/greeting", nil, "Accept-Encoding", "gzip")
+	w := send(r, "GET", "/greeting", nil)
+	assert.Equal(t, "MISS", w.Header().Get(CacheHeader))
+	assert.Equal(t, "", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, ResponseBody, w.Body.String())
+
+	send(r, "GET", "/theme", nil, "Sec-CH-Prefers-Color-Scheme", "dark")
+	w = send(r, "GET", "/theme", nil, "Sec-CH-Prefers-Color-Scheme", "light")
+	assert.Equal(t, "light", w.Body.String())
+}
+
+func TestCacheRoutePolicy(t *testing.T) {
+	calls := 0
+	r := newCacheRouter(&calls)
+
+	w := send(r, "GET", "/private", nil)
+	assert.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+	assert.Equal(t, "", w.Header().Get(CacheHeader))
+
+	w = send(r, "GET", "/private", nil, "If-Modified-Since", "Mon, 02 Jan 2006 15:04:05 GMT")
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, 2, calls)
+}
+
+func TestLRUStore(t *testing.T) {
+	s := NewLRUStore(2)
+	s.Set("a", &CachedResponse{Status: 200}, time.Minute)
+	s.Set("b", &CachedResponse{Status: 200}, time.Minute)
+	_, ok := s.Get("a")
+	assert.True(t, ok)
+
+	s.Set("c", &CachedResponse{Status: 200}, time.Minute)
+	assert.Equal(t, 2, s.Len())
+	_, ok = s.Get("b")
+	assert.False(t, ok)
+
+	s.Set("d", &CachedResponse{Status: 200}, -time.Second)
+	_, ok = s.Get("d")
+	assert.False(t, ok)
+}
@@ -0,0 +1,128 @@
+package xrouter
+
+import (
+	"context"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"strings"
+
+	"github.com/rs/xlog"
+)
+
+// Variants selected by Canary.
+const (
+	StableVariant = "stable"
+	CanaryVariant = "canary"
+)
+
+// FlagEvaluator decides whether a flag is enabled for a request. It returns false for ok when it has no opinion, in
+// which case the percentage rollout applies.
+type FlagEvaluator func(r *http.Request, flag string) (enabled, ok bool)
+
+// CanaryOptions configures how Canary selects between two handlers.
+type CanaryOptions struct {
+	// Flag names the rollout. It is recorded in the context and logs and seeds the sticky assignment. Defaults to
+	// "canary".
+	Flag string
+
+	// Percent is the share of requests, from 0 to 100, which are sent to the canary.
+	Percent float64
+
+	// Header and Cookie name a request header or cookie which forces a variant when set to "canary" or "stable".
+	Header string
+	Cookie string
+
+	// Evaluator is consulted after the header and cookie and before the percentage rollout.
+	Evaluator FlagEvaluator
+
+	// Key returns the key, such as a user ID, used to keep assigning a user to the same variant. Requests with an
+	// empty key are assigned randomly.
+	Key func(*http.Request) string
+}
+
+// Canary returns a route which sends each request to either the stable or the canary handler. A variant forced by the
+// header or cookie is used first, then the evaluator's decision and finally the percentage rollout. The variant is
+// available from VariantFromContext and is added to the request's log fields.
+func Canary(stable, canary Route, opts CanaryOptions) Route {
+	if opts.Flag == "" {
+		opts.Flag = "canary"
+	}
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		variant := opts.selectVariant(r)
+		xlog.FromContext(ctx).SetField("flag:"+opts.Flag, variant)
+
+		variants := map[string]string{opts.Flag: variant}
+		if parent, ok := ctx.Value(variantKey).(map[string]string); ok {
+			for flag, v := range parent {
+				if flag != opts.Flag {
+					variants[flag] = v
+				}
+			}
+		}
+		ctx = context.WithValue(ctx, variantKey, variants)
+		r = r.WithContext(ctx)
+
+		if variant == CanaryVariant {
+			canary(ctx, w, r)
+			return
+		}
+		stable(ctx, w, r)
+	}
+}
+
+// VariantFromContext returns the variant selected for a flag by Canary, or an empty string if the request did not
+// pass through a Canary route with that flag.
+func VariantFromContext(ctx context.Context, flag string) string {
+	variants, _ := ctx.Value(variantKey).(map[string]string)
+	return variants[flag]
+}
+
+func (o *CanaryOptions) selectVariant(r *http.Request) string {
+	if o.Header != "" {
+		if v := forcedVariant(r.Header.Get(o.Header)); v != "" {
+			return v
+		}
+	}
+	if o.Cookie != "" {
+		if c, err := r.Cookie(o.Cookie); err == nil {
+			if v := forcedVariant(c.Value); v != "" {
+				return v
+			}
+		}
+	}
+	if o.Evaluator != nil {
+		if enabled, ok := o.Evaluator(r, o.Flag); ok {
+			if enabled {
+				return CanaryVariant
+			}
+			return StableVariant
+		}
+	}
+
+	var key string
+	if o.Key != nil {
+		key = o.Key(r)
+	}
+	var bucket float64
+	if key != "" {
+		h := fnv.New32a()
+		h.Write([]byte(o.Flag + "\x00" + key))
+		bucket = float64(h.Sum32()%10000) / 100
+	} else {
+		bucket = rand.Float64() * 100
+	}
+	if bucket < o.Percent {
+		return CanaryVariant
+	}
+	return StableVariant
+}
+
+// forcedVariant parses a variant set by a client, ignoring unknown values.
+func forcedVariant(value string) string {
+	switch v := strings.ToLower(strings.TrimSpace(value)); v {
+	case CanaryVariant, StableVariant:
+		return v
+	}
+	return ""
+}
@@ -0,0 +1,84 @@
+package xrouter
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/rs/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func variantRoute(name string) Route {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(name + " " + VariantFromContext(ctx, "checkout")))
+	}
+}
+
+func newCanaryRouter(opts CanaryOptions) Router {
+	opts.Flag = "checkout"
+	r := New()
+	r.GET("/checkout", Canary(variantRoute("old"), variantRoute("new"), opts))
+	return r
+}
+
+func callCanary(r Router, header ...string) string {
+	return send(r, "GET", "/checkout", nil, header...).Body.String()
+}
+
+func TestCanaryPercent(t *testing.T) {
+	assert.Equal(t, "old stable", callCanary(newCanaryRouter(CanaryOptions{Percent: 0})))
+	assert.Equal(t, "new canary", callCanary(newCanaryRouter(CanaryOptions{Percent: 100})))
+
+	r := newCanaryRouter(CanaryOptions{Percent: 30, Key: func(r *http.Request) string { return r.Header.Get("X-User") }})
+	canaries := 0
+	for i := 0; i < 1000; i++ {
+		user := []string{"X-User", strconv.Itoa(i)}
+		first := callCanary(r, user...)
+		assert.Equal(t, first, callCanary(r, user...), "assignment must be sticky")
+		if first == "new canary" {
+			canaries++
+		}
+	}
+	assert.InDelta(t, 300, canaries, 60)
+}
+
+func TestCanaryForcedVariant(t *testing.T) {
+	r := newCanaryRouter(CanaryOptions{Percent: 100, Header: "X-Canary", Cookie: "canary"})
+	assert.Equal(t, "old stable", callCanary(r, "X-Canary", "stable"))
+	assert.Equal(t, "old stable", callCanary(r, "Cookie", "canary=stable"))
+	assert.Equal(t, "new canary", callCanary(r, "X-Canary", "bogus"))
+
+	r = newCanaryRouter(CanaryOptions{Percent: 0, Header: "X-Canary"})
+	assert.Equal(t, "new canary", callCanary(r, "X-Canary", "Canary"))
+}
+
+func TestCanaryEvaluator(t *testing.T) {
+	r := newCanaryRouter(CanaryOptions{Evaluator: func(r *http.Request, flag string) (bool, bool) {
+		switch r.Header.Get("X-Plan") {
+		case "beta":
+			return flag == "checkout", true
+		case "free":
+			return false, true
+		}
+		return false, false
+	}, Percent: 100})
+
+	assert.Equal(t, "new canary", callCanary(r, "X-Plan", "beta"))
+	assert.Equal(t, "old stable", callCanary(r, "X-Plan", "free"))
+	assert.Equal(t, "new canary", callCanary(r))
+}
+
+func TestCanaryLogsVariant(t *testing.T) {
+	logs := &logCapture{}
+	r := New()
+	r.Use(xlog.NewHandler(xlog.Config{Output: logs}))
+	r.Use(LogHandler())
+	r.GET("/checkout", Canary(variantRoute("old"), variantRoute("new"), CanaryOptions{Flag: "checkout", Percent: 100}))
+
+	send(r, "GET", "/checkout", nil)
+	if assert.Len(t, logs.messages, 1) {
+		assert.Equal(t, "canary", logs.messages[0]["flag:checkout"])
+	}
+}
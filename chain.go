@@ -0,0 +1,165 @@
+package xrouter
+
+import (
+	"net/http"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/justinas/alice"
+)
+
+// namedMiddleware is a middleware function and the name used to reorder or remove it.
+type namedMiddleware struct {
+	name string
+	f    func(http.Handler) http.Handler
+}
+
+// chainOp modifies the middleware inherited by a group.
+type chainOp func([]namedMiddleware) []namedMiddleware
+
+// middlewareName names unnamed middleware after the function which implements it, such as xrouter.LogHandler.func1.
+func middlewareName(f func(http.Handler) http.Handler) string {
+	name := runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+func indexMiddleware(chain []namedMiddleware, name string) int {
+	return slices.IndexFunc(chain, func(m namedMiddleware) bool { return m.name == name })
+}
+
+// middleware returns the effective middleware of the group: the current middleware of its parent followed by the
+// group's own changes, in the order they were made.
+func (r *routerGroup) middleware() []namedMiddleware {
+	var chain []namedMiddleware
+	if r.parent != nil {
+		chain = r.parent.middleware()
+	}
+	for _, op := range r.ops {
+		chain = op(chain)
+	}
+	return chain
+}
+
+// addOp records a change to the group's middleware and invalidates the compiled chains of every route.
+func (r *routerGroup) addOp(op chainOp) {
+	r.ops = append(r.ops, op)
+	r.table.generation.Add(1)
+}
+
+// Use adds middleware to the group. Since groups inherit middleware when their routes are served, middleware added to
+// a parent after a child group was created still applies to the child. The middleware is named after its function,
+// with a #2, #3, ... suffix if the group already has middleware of that name, so that Without removes only the first.
+func (r *routerGroup) Use(f func(next http.Handler) http.Handler) {
+	name := middlewareName(f)
+	chain := r.middleware()
+	for i := 2; indexMiddleware(chain, name) >= 0; i++ {
+		name = middlewareName(f) + "#" + strconv.Itoa(i)
+	}
+	r.UseNamed(name, f)
+}
+
+// UseNamed adds middleware which can be referenced by name with UseBefore, UseAfter and Without.
+func (r *routerGroup) UseNamed(name string, f func(next http.Handler) http.Handler) {
+	r.addOp(func(chain []namedMiddleware) []namedMiddleware {
+		return append(chain, namedMiddleware{name, f})
+	})
+}
+
+// UseBefore inserts named middleware before the middleware called before. It panics if there is no such middleware.
+func (r *routerGroup) UseBefore(before, name string, f func(next http.Handler) http.Handler) {
+	r.insert(before, 0, namedMiddleware{name, f})
+}
+
+// UseAfter inserts named middleware after the middleware called after. It panics if there is no such middleware.
+func (r *routerGroup) UseAfter(after, name string, f func(next http.Handler) http.Handler) {
+	r.insert(after, 1, namedMiddleware{name, f})
+}
+
+func (r *routerGroup) insert(anchor string, offset int, m namedMiddleware) {
+	if indexMiddleware(r.middleware(), anchor) < 0 {
+		panic("xrouter: no middleware named " + anchor + " in group " + r.Path())
+	}
+	r.addOp(func(chain []namedMiddleware) []namedMiddleware {
+		i := indexMiddleware(chain, anchor)
+		if i < 0 {
+			return append(chain, m)
+		}
+		return slices.Insert(slices.Clone(chain), i+offset, m)
+	})
+}
+
+// Without removes the named middleware from the group and its child groups, including middleware inherited from
+// parent groups.
+func (r *routerGroup) Without(names ...string) {
+	r.addOp(func(chain []namedMiddleware) []namedMiddleware {
+		return slices.DeleteFunc(slices.Clone(chain), func(m namedMiddleware) bool {
+			return slices.Contains(names, m.name)
+		})
+	})
+}
+
+// Chain returns the effective middleware chain of the group.
+func (r *routerGroup) Chain() alice.Chain {
+	var constructors []alice.Constructor
+	for _, m := range r.middleware() {
+		constructors = append(constructors, m.f)
+	}
+	return alice.New(constructors...)
+}
+
+// middlewareNames returns the names of the group's effective middleware, outermost first.
+func (r *routerGroup) middlewareNames() []string {
+	var names []string
+	for _, m := range r.middleware() {
+		names = append(names, m.name)
+	}
+	return names
+}
+
+// live wraps a handler with the group's effective middleware. The chain is rebuilt by the first request after
+// middleware changes anywhere in the router so that middleware added after the handler was registered still applies.
+// Middleware constructors therefore run again on each rebuild and should only build the handler, keeping any shared
+// state outside of the constructor.
+func (r *routerGroup) live(h http.Handler) http.Handler {
+	return &liveHandler{group: r, handler: h}
+}
+
+type liveHandler struct {
+	group    *routerGroup
+	handler  http.Handler
+	compiled atomic.Pointer[compiledChain]
+
+	// mu serializes rebuilds so that concurrent requests build each generation once.
+	mu sync.Mutex
+}
+
+type compiledChain struct {
+	generation uint64
+	handler    http.Handler
+}
+
+func (l *liveHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	c := l.compiled.Load()
+	if c == nil || c.generation != l.group.table.generation.Load() {
+		c = l.compile()
+	}
+	c.handler.ServeHTTP(w, req)
+}
+
+// compile builds the chain for the current generation unless another request already has.
+func (l *liveHandler) compile() *compiledChain {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	generation := l.group.table.generation.Load()
+	if c := l.compiled.Load(); c != nil && c.generation == generation {
+		return c
+	}
+	c := &compiledChain{generation, l.group.Chain().Then(l.handler)}
+	l.compiled.Store(c)
+	return c
+}
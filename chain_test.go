@@ -0,0 +1,142 @@
+package xrouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// traceMiddleware appends its name to the X-Trace response header.
+func traceMiddleware(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Trace", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func trace(r Router, path string) string {
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	return strings.Join(w.Header().Values("X-Trace"), ",")
+}
+
+func TestLiveMiddlewareInheritance(t *testing.T) {
+	r := New()
+	r.UseNamed("log", traceMiddleware("log"))
+	api := r.Group("/api")
+	api.GET("/users", GetTest)
+
+	// Middleware added to the parent after the group and route were created still applies.
+	r.UseNamed("metrics", traceMiddleware("metrics"))
+	api.UseNamed("auth", traceMiddleware("auth"))
+	assert.Equal(t, "log,metrics,auth", trace(r, "/api/users"))
+
+	r.UseNamed("recover", traceMiddleware("recover"))
+	assert.Equal(t, "log,metrics,recover,auth", trace(r, "/api/users"))
+}
+
+func TestUseBeforeAfterWithout(t *testing.T) {
+	r := New()
+	r.UseNamed("log", traceMiddleware("log"))
+	r.UseNamed("auth", traceMiddleware("auth"))
+
+	api := r.Group("/api")
+	api.UseBefore("auth", "ratelimit", traceMiddleware("ratelimit"))
+	api.UseAfter("log", "requestid", traceMiddleware("requestid"))
+	api.GET("/users", GetTest)
+
+	public := api.Group("/public")
+	public.Without("auth")
+	public.GET("/status", GetTest)
+
+	r.GET("/home", GetTest)
+
+	assert.Equal(t, "log,requestid,ratelimit,auth", trace(r, "/api/users"))
+	assert.Equal(t, "log,requestid,ratelimit", trace(r, "/api/public/status"))
+	assert.Equal(t, "log,auth", trace(r, "/home"))
+
+	assert.Panics(t, func() {
+		api.UseBefore("missing", "x", traceMiddleware("x"))
+	})
+}
+
+func TestRouteMiddlewareIntrospection(t *testing.T) {
+	r := New()
+	r.Use(LogHandler())
+	api := r.Group("/api")
+	api.UseNamed("auth", traceMiddleware("auth"))
+	api.GET("/users", GetTest)
+
+	info, _, ok := r.Lookup("GET", "/api/users")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"xrouter.LogHandler.func1", "auth"}, info.Middleware)
+
+	api.Without("auth")
+	assert.Equal(t, []string{"xrouter.LogHandler.func1"}, r.Routes()[0].Middleware)
+}
+
+func TestGroupNotFoundUsesLiveMiddleware(t *testing.T) {
+	r := New()
+	api := r.Group("/api")
+	api.NotFound(http.NotFoundHandler())
+	r.UseNamed("log", traceMiddleware("log"))
+
+	assert.Equal(t, "log", trace(r, "/api/missing"))
+}
+
+func TestLiveChainBuiltOncePerChange(t *testing.T) {
+	var built atomic.Int32
+	r := New()
+	r.Use(func(next http.Handler) http.Handler {
+		built.Add(1)
+		return next
+	})
+	r.GET("/", GetTest)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			trace(r, "/")
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), built.Load())
+
+	r.UseNamed("log", traceMiddleware("log"))
+	trace(r, "/")
+	trace(r, "/")
+	assert.Equal(t, int32(2), built.Load())
+}
+
+func TestUseNamesRepeatedMiddleware(t *testing.T) {
+	r := New()
+	r.Use(LogHandler())
+	r.Use(LogHandler())
+	r.GET("/", GetTest)
+	assert.Equal(t, []string{"xrouter.LogHandler.func1", "xrouter.LogHandler.func1#2"}, r.Routes()[0].Middleware)
+
+	r.Without("xrouter.LogHandler.func1")
+	assert.Equal(t, []string{"xrouter.LogHandler.func1#2"}, r.Routes()[0].Middleware)
+}
+
+func TestDefaultsInheritedByExistingGroups(t *testing.T) {
+	r := New()
+	api := r.Group("/api")
+	api.GET("/before", GetTest)
+	r.Defaults(Tags("public"))
+	api.GET("/after", GetTest)
+
+	before, _, _ := r.Lookup("GET", "/api/before")
+	after, _, _ := r.Lookup("GET", "/api/after")
+	assert.Empty(t, before.Tags)
+	assert.Equal(t, []string{"public"}, after.Tags)
+}
@@ -0,0 +1,255 @@
+package xrouter
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CompressOptions configures the Compress middleware. Zero values use the defaults listed for each field.
+type CompressOptions struct {
+	// Level is the compression level used by both encoders, from gzip.HuffmanOnly to gzip.BestCompression. Defaults to
+	// gzip.DefaultCompression. Since zero selects the default, use NoCompression for gzip.NoCompression.
+	Level int
+
+	// MinSize is the smallest response body, in bytes, which will be compressed. Defaults to 1024.
+	MinSize int
+
+	// SkipContentTypes lists content type prefixes which are never compressed because they are already compressed.
+	// Defaults to DefaultSkipContentTypes.
+	SkipContentTypes []string
+}
+
+// NoCompression selects gzip.NoCompression as the CompressOptions level, whose zero value is the default level.
+const NoCompression = -3
+
+// DefaultSkipContentTypes are the content types which are already compressed.
+var DefaultSkipContentTypes = []string{
+	"image/", "video/", "audio/", "font/woff",
+	"application/zip", "application/gzip", "application/x-gzip", "application/zstd",
+	"application/x-7z-compressed", "application/x-rar-compressed", "application/x-bzip2", "application/pdf",
+}
+
+// encoders lists the supported content codings in order of preference when the client weights them equally.
+var encoders = []string{"gzip", "deflate"}
+
+// Compress returns middleware which compresses responses with gzip or deflate, as negotiated with the Accept-Encoding
+// header. Small bodies, already compressed content and responses which set their own Content-Encoding are sent
+// unchanged. Flushing is supported so that streaming responses are delivered as they are written.
+func Compress(opts CompressOptions) func(http.Handler) http.Handler {
+	switch {
+	case opts.Level == 0:
+		opts.Level = gzip.DefaultCompression
+	case opts.Level == NoCompression:
+		opts.Level = gzip.NoCompression
+	case opts.Level < gzip.HuffmanOnly || opts.Level > gzip.BestCompression:
+		panic("xrouter: invalid compression level " + strconv.Itoa(opts.Level))
+	}
+	if opts.MinSize == 0 {
+		opts.MinSize = 1024
+	}
+	if opts.SkipContentTypes == nil {
+		opts.SkipContentTypes = DefaultSkipContentTypes
+	}
+
+	pools := map[string]*sync.Pool{
+		"gzip": {New: func() interface{} {
+			w, _ := gzip.NewWriterLevel(io.Discard, opts.Level)
+			return w
+		}},
+		// The HTTP deflate coding is a zlib stream, not raw deflate.
+		"deflate": {New: func() interface{} {
+			w, _ := zlib.NewWriterLevel(io.Discard, opts.Level)
+			return w
+		}},
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == "" || r.Method == "HEAD" || r.Header.Get("Range") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, encoding: encoding, opts: &opts, pool: pools[encoding]}
+			defer cw.Close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding selects the supported encoding with the highest quality in an Accept-Encoding header.
+func negotiateEncoding(header string) string {
+	best, bestQ := "", 0.0
+	for _, enc := range encoders {
+		if q := acceptQuality(header, enc); q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+// acceptQuality returns the quality value of a coding in an Accept-Encoding header, falling back to the wildcard.
+func acceptQuality(header, coding string) float64 {
+	q, wildcard := -1.0, -1.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		value := 1.0
+		if p, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(p, 64); err == nil {
+				value = v
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case coding:
+			q = value
+		case "*":
+			wildcard = value
+		}
+	}
+	if q < 0 {
+		q = wildcard
+	}
+	return q
+}
+
+// compressWriter buffers the start of a response until it can decide whether to compress it.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	opts     *CompressOptions
+	pool     *sync.Pool
+
+	buf        []byte
+	status     int
+	decided    bool
+	compressor compressor
+}
+
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+func (c *compressWriter) WriteHeader(code int) {
+	if c.status != 0 || c.decided {
+		return
+	}
+	c.status = code
+	if code < 200 || code == http.StatusNoContent || code == http.StatusNotModified {
+		c.start(false)
+	}
+}
+
+func (c *compressWriter) Write(p []byte) (int, error) {
+	if !c.decided {
+		c.buf = append(c.buf, p...)
+		if len(c.buf) < c.opts.MinSize {
+			return len(p), nil
+		}
+		c.decide()
+		return len(p), c.flushBuffer()
+	}
+	if c.compressor != nil {
+		return c.compressor.Write(p)
+	}
+	return c.ResponseWriter.Write(p)
+}
+
+// decide starts the response, compressing it unless the content should not be compressed.
+func (c *compressWriter) decide() {
+	h := c.Header()
+	if h.Get("Content-Type") == "" && len(c.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(c.buf))
+	}
+	compress := h.Get("Content-Encoding") == ""
+	ct := strings.ToLower(h.Get("Content-Type"))
+	for _, skip := range c.opts.SkipContentTypes {
+		if strings.HasPrefix(ct, skip) {
+			compress = false
+		}
+	}
+	c.start(compress)
+}
+
+// start writes the response header, setting up the compressor if the response is compressed.
+func (c *compressWriter) start(compress bool) {
+	c.decided = true
+	if compress {
+		c.Header().Set("Content-Encoding", c.encoding)
+		c.Header().Del("Content-Length")
+		c.compressor = c.pool.Get().(compressor)
+		c.compressor.Reset(c.ResponseWriter)
+	}
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	c.ResponseWriter.WriteHeader(c.status)
+}
+
+func (c *compressWriter) flushBuffer() error {
+	if len(c.buf) == 0 {
+		return nil
+	}
+	var err error
+	if c.compressor != nil {
+		_, err = c.compressor.Write(c.buf)
+	} else {
+		_, err = c.ResponseWriter.Write(c.buf)
+	}
+	c.buf = nil
+	return err
+}
+
+// Flush starts the response if it has not been started, then flushes the compressor and the underlying writer.
+func (c *compressWriter) Flush() {
+	if !c.decided {
+		c.decide()
+		c.flushBuffer()
+	}
+	if c.compressor != nil {
+		c.compressor.Flush()
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close writes any buffered data and returns the compressor to the pool.
+func (c *compressWriter) Close() error {
+	if !c.decided {
+		if len(c.buf) == 0 && c.status == 0 {
+			return nil
+		}
+		c.start(false)
+	}
+	err := c.flushBuffer()
+	if c.compressor != nil {
+		if cerr := c.compressor.Close(); err == nil {
+			err = cerr
+		}
+		c.compressor.Reset(io.Discard)
+		c.pool.Put(c.compressor)
+		c.compressor = nil
+	}
+	return err
+}
+
+func (c *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return c.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// Unwrap returns the underlying ResponseWriter for use with http.ResponseController.
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
@@ -0,0 +1,114 @@
+package xrouter
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var largeBody = strings.Repeat("Hello, World! ", 200)
+
+func newCompressRouter() Router {
+	r := New()
+	r.Use(Compress(CompressOptions{}))
+	r.GET("/large", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(largeBody))
+	})
+	r.GET("/small", GetTest)
+	r.GET("/image", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte(largeBody))
+	})
+	r.GET("/stream", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: hello\n\n"))
+		w.(http.Flusher).Flush()
+	})
+	return r
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	assert.Equal(t, "gzip", negotiateEncoding("gzip, deflate, br"))
+	assert.Equal(t, "deflate", negotiateEncoding("gzip;q=0.5, deflate"))
+	assert.Equal(t, "deflate", negotiateEncoding("gzip;q=0, *"))
+	assert.Equal(t, "gzip", negotiateEncoding("*"))
+	assert.Equal(t, "", negotiateEncoding("br, identity"))
+	assert.Equal(t, "", negotiateEncoding(""))
+}
+
+func TestCompressGzip(t *testing.T) {
+	w := send(newCompressRouter(), "GET", "/large", nil, "Accept-Encoding", "gzip")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	assert.True(t, w.Body.Len() < len(largeBody))
+
+	gr, err := gzip.NewReader(w.Body)
+	assert.NoError(t, err)
+	body, err := ioutil.ReadAll(gr)
+	assert.NoError(t, err)
+	assert.Equal(t, largeBody, string(body))
+}
+
+func TestCompressLevel(t *testing.T) {
+	r := New()
+	r.Use(Compress(CompressOptions{Level: NoCompression}))
+	r.GET("/large", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(largeBody))
+	})
+
+	// Stored blocks carry the body as is.
+	w := send(r, "GET", "/large", nil, "Accept-Encoding", "gzip")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Contains(t, w.Body.String(), largeBody)
+
+	assert.Panics(t, func() { Compress(CompressOptions{Level: 10}) })
+}
+
+func TestCompressDeflate(t *testing.T) {
+	w := send(newCompressRouter(), "GET", "/large", nil, "Accept-Encoding", "deflate")
+	assert.Equal(t, "deflate", w.Header().Get("Content-Encoding"))
+	zr, err := zlib.NewReader(w.Body)
+	if !assert.NoError(t, err) {
+		return
+	}
+	body, err := ioutil.ReadAll(zr)
+	assert.NoError(t, err)
+	assert.Equal(t, largeBody, string(body))
+}
+
+func TestCompressSkipped(t *testing.T) {
+	r := newCompressRouter()
+
+	w := send(r, "GET", "/small", nil, "Accept-Encoding", "gzip")
+	assert.Equal(t, "", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	assert.Equal(t, ResponseBody, w.Body.String())
+
+	w = send(r, "GET", "/image", nil, "Accept-Encoding", "gzip")
+	assert.Equal(t, "", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, largeBody, w.Body.String())
+
+	w = send(r, "GET", "/large", nil)
+	assert.Equal(t, "", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, largeBody, w.Body.String())
+}
+
+func TestCompressFlush(t *testing.T) {
+	w := send(newCompressRouter(), "GET", "/stream", nil, "Accept-Encoding", "gzip")
+	assert.True(t, w.Flushed)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+
+	gr, err := gzip.NewReader(w.Body)
+	assert.NoError(t, err)
+	body, err := ioutil.ReadAll(gr)
+	assert.NoError(t, err)
+	assert.Equal(t, "data: hello\n\n", string(body))
+}
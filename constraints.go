@@ -0,0 +1,150 @@
+package xrouter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Named parameter constraints. Any other constraint is treated as a regular expression which must match the whole
+// value, so /users/:id<int> only matches numeric IDs and /files/:name<[a-z0-9-]+> only matches slugs.
+var namedConstraints = map[string]string{
+	"int":   `[-+]?[0-9]+`,
+	"uint":  `[0-9]+`,
+	"float": `[-+]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][-+]?[0-9]+)?`,
+	"bool":  `true|false|1|0`,
+	"uuid":  `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+	"alpha": `[a-zA-Z]+`,
+}
+
+// compiledConstraints caches the compiled expressions of constraints.
+var compiledConstraints sync.Map
+
+// parseConstraints removes the constraints from a path, returning the httprouter pattern and the constraint of each
+// constrained parameter.
+func parseConstraints(path string) (string, map[string]string, error) {
+	if !strings.Contains(path, "<") {
+		return path, nil, nil
+	}
+
+	var b strings.Builder
+	var constraints map[string]string
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c != ':' && c != '*' {
+			b.WriteByte(c)
+			continue
+		}
+
+		end := i + 1
+		for end < len(path) && path[end] != '/' && path[end] != '<' {
+			end++
+		}
+		name := path[i+1 : end]
+		b.WriteString(path[i:end])
+		i = end - 1
+		if end == len(path) || path[end] != '<' {
+			continue
+		}
+
+		depth, closing := 0, -1
+		for j := end; j < len(path) && closing < 0; j++ {
+			switch path[j] {
+			case '<':
+				depth++
+			case '>':
+				if depth--; depth == 0 {
+					closing = j
+				}
+			}
+		}
+		if closing < 0 {
+			return "", nil, errors.New("unterminated constraint for parameter " + name)
+		}
+		expr := path[end+1 : closing]
+		if _, err := compileConstraint(expr); err != nil {
+			return "", nil, errors.New("invalid constraint for parameter " + name + ": " + err.Error())
+		}
+		if constraints == nil {
+			constraints = make(map[string]string)
+		}
+		constraints[name] = expr
+		i = closing
+	}
+	return b.String(), constraints, nil
+}
+
+// compileConstraint compiles a named or regular expression constraint into an anchored expression.
+func compileConstraint(expr string) (*regexp.Regexp, error) {
+	if re, ok := compiledConstraints.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	pattern := expr
+	if named, ok := namedConstraints[expr]; ok {
+		pattern = named
+	}
+	re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+	if err != nil {
+		return nil, err
+	}
+	compiledConstraints.Store(expr, re)
+	return re, nil
+}
+
+// matchConstraints reports whether every constrained parameter satisfies its constraint. Catch-all values are matched
+// without their leading slash.
+func matchConstraints(constraints map[string]string, params httprouter.Params) bool {
+	for name, expr := range constraints {
+		re, err := compileConstraint(expr)
+		if err != nil || !re.MatchString(strings.TrimPrefix(params.ByName(name), "/")) {
+			return false
+		}
+	}
+	return true
+}
+
+// constrain checks the route's constraints before the middleware and route run, answering mismatches with the
+// router's NotFound handler.
+func (r *routerGroup) constrain(constraints map[string]string, h httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		if matchConstraints(constraints, params) {
+			h(w, req, params)
+			return
+		}
+		if r.router.NotFound != nil {
+			r.router.NotFound.ServeHTTP(w, req)
+			return
+		}
+		http.NotFound(w, req)
+	}
+}
+
+// ParamInt returns a URL parameter as an integer. The result is false if the parameter is missing or not an integer.
+func ParamInt(ctx context.Context, key string) (int64, bool) {
+	v, err := strconv.ParseInt(Param(ctx, key), 10, 64)
+	return v, err == nil
+}
+
+// ParamUint returns a URL parameter as an unsigned integer.
+func ParamUint(ctx context.Context, key string) (uint64, bool) {
+	v, err := strconv.ParseUint(Param(ctx, key), 10, 64)
+	return v, err == nil
+}
+
+// ParamFloat returns a URL parameter as a floating point number.
+func ParamFloat(ctx context.Context, key string) (float64, bool) {
+	v, err := strconv.ParseFloat(Param(ctx, key), 64)
+	return v, err == nil
+}
+
+// ParamBool returns a URL parameter as a boolean.
+func ParamBool(ctx context.Context, key string) (bool, bool) {
+	v, err := strconv.ParseBool(Param(ctx, key))
+	return v, err == nil
+}
@@ -0,0 +1,129 @@
+package xrouter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newConstrainedRouter() Router {
+	r := New()
+	r.GET("/users/:id<int>", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		id, ok := ParamInt(ctx, "id")
+		fmt.Fprintf(w, "user %d %v", id, ok)
+	})
+	r.GET("/files/:name<[a-z0-9-]+>/raw", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("file " + Param(ctx, "name")))
+	})
+	r.GET("/flags/:on<bool>", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		on, _ := ParamBool(ctx, "on")
+		fmt.Fprintf(w, "flag %v", on)
+	})
+	r.GET("/static/*path<[a-z/]+\\.css>", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("css " + Param(ctx, "path")))
+	})
+	return r
+}
+
+func TestConstraintsMatch(t *testing.T) {
+	r := newConstrainedRouter()
+
+	assert.Equal(t, "user 42 true", send(r, "GET", "/users/42", nil).Body.String())
+	assert.Equal(t, "file my-file-1", send(r, "GET", "/files/my-file-1/raw", nil).Body.String())
+	assert.Equal(t, "flag true", send(r, "GET", "/flags/true", nil).Body.String())
+	assert.Equal(t, "css /site/main.css", send(r, "GET", "/static/site/main.css", nil).Body.String())
+}
+
+func TestConstraintsMismatch(t *testing.T) {
+	r := newConstrainedRouter()
+
+	assert.Equal(t, http.StatusNotFound, send(r, "GET", "/users/abc", nil).Code)
+	assert.Equal(t, http.StatusNotFound, send(r, "GET", "/users/4.2", nil).Code)
+	assert.Equal(t, http.StatusNotFound, send(r, "GET", "/files/My_File/raw", nil).Code)
+	assert.Equal(t, http.StatusNotFound, send(r, "GET", "/flags/maybe", nil).Code)
+	assert.Equal(t, http.StatusNotFound, send(r, "GET", "/static/site/main.js", nil).Code)
+
+	called := false
+	r.NotFound(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	send(r, "GET", "/users/abc", nil)
+	assert.True(t, called)
+}
+
+func TestConstraintsRunBeforeMiddleware(t *testing.T) {
+	r := New()
+	calls := 0
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			next.ServeHTTP(w, r)
+		})
+	})
+	r.GET("/users/:id<uuid>", GetTest)
+
+	send(r, "GET", "/users/42", nil)
+	assert.Equal(t, 0, calls)
+	send(r, "GET", "/users/123e4567-e89b-12d3-a456-426614174000", nil)
+	assert.Equal(t, 1, calls)
+}
+
+func TestConstraintsIntrospection(t *testing.T) {
+	r := newConstrainedRouter()
+
+	info, params, ok := r.Lookup("GET", "/users/7")
+	assert.True(t, ok)
+	assert.Equal(t, "/users/:id", info.Path)
+	assert.Equal(t, map[string]string{"id": "int"}, info.Constraints)
+	assert.Equal(t, "7", params.ByName("id"))
+
+	_, _, ok = r.Lookup("GET", "/users/seven")
+	assert.False(t, ok)
+
+	info, _, _ = r.Lookup("GET", "/files/a-b/raw")
+	assert.Equal(t, "/files/:name/raw", info.Path)
+	assert.Equal(t, "[a-z0-9-]+", info.Constraints["name"])
+}
+
+func TestConstraintsOpenAPI(t *testing.T) {
+	r := newConstrainedRouter()
+	doc := GenerateOpenAPI(OpenAPIOptions{}, r.Routes())
+	paths := doc["paths"].(map[string]interface{})
+
+	param := func(path string) map[string]interface{} {
+		op := paths[path].(map[string]interface{})["get"].(map[string]interface{})
+		return op["parameters"].([]interface{})[0].(map[string]interface{})["schema"].(map[string]interface{})
+	}
+	assert.Equal(t, "integer", param("/users/{id}")["type"])
+	assert.Equal(t, "^(?:[a-z0-9-]+)$", param("/files/{name}/raw")["pattern"])
+	assert.Equal(t, "boolean", param("/flags/{on}")["type"])
+}
+
+func TestConstraintsInvalid(t *testing.T) {
+	r := New(CollectErrors())
+	r.GET("/users/:id<[a-z>", GetTest)
+	r.GET("/users/:id<int", GetTest)
+
+	err := r.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid constraint for parameter id")
+	assert.Contains(t, err.Error(), "unterminated constraint for parameter id")
+	assert.Empty(t, r.Routes())
+}
+
+func TestParamAccessors(t *testing.T) {
+	r := New()
+	r.GET("/values/:i/:u/:f/:b", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		i, _ := ParamInt(ctx, "i")
+		u, _ := ParamUint(ctx, "u")
+		f, _ := ParamFloat(ctx, "f")
+		b, _ := ParamBool(ctx, "b")
+		_, missing := ParamInt(ctx, "missing")
+		fmt.Fprintf(w, "%d %d %g %v %v", i, u, f, b, missing)
+	})
+	assert.Equal(t, "-3 7 2.5 true false", send(r, "GET", "/values/-3/7/2.5/true", nil).Body.String())
+}
@@ -0,0 +1,376 @@
+package xrouter
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CSRFMode selects how the CSRF middleware keeps the token of a client.
+type CSRFMode int
+
+const (
+	// CSRFDoubleSubmit keeps the token in a cookie which must be echoed in a header or form field. Setting both
+	// CSRFOptions.Secret and CSRFOptions.Session binds the cookie to the session, so that a cookie planted by a sibling
+	// domain is rejected.
+	CSRFDoubleSubmit CSRFMode = iota
+
+	// CSRFSynchronizer keeps the token in a CSRFStore, keyed by the session returned by CSRFOptions.Session.
+	CSRFSynchronizer
+)
+
+// CSRFStore holds the synchronizer tokens of sessions.
+type CSRFStore interface {
+	Get(session string) (string, bool)
+	Set(session, token string)
+}
+
+// CSRFOptions configures the CSRF middleware.
+type CSRFOptions struct {
+	// Mode selects double-submit cookies or synchronizer tokens. Defaults to double-submit cookies.
+	Mode CSRFMode
+
+	// Secret signs double-submit cookies, together with the session returned by Session, when set. Without a Session
+	// the signature only proves that the cookie was issued by this server, not to whom.
+	Secret []byte
+
+	// Session returns the session of a request, such as the session cookie value. It is required in synchronizer mode
+	// and binds signed cookies to the session in double-submit mode.
+	Session func(*http.Request) string
+
+	// Store holds synchronizer tokens. Defaults to a MemoryCSRFStore keeping tokens for 12 hours after their last use.
+	Store CSRFStore
+
+	// HeaderName and FieldName name the request header and form field which carry the token. They default to
+	// X-CSRF-Token and csrf_token.
+	HeaderName string
+	FieldName  string
+
+	// TrustedOrigins lists the origins, besides the request's own, which may send state-changing requests, such as
+	// "https://admin.example.com". An entry without a scheme, such as "admin.example.com", only matches origins with
+	// the scheme of the request.
+	TrustedOrigins []string
+
+	// TrustForwarded takes the scheme of requests from the X-Forwarded-Proto header, for servers behind a proxy which
+	// terminates TLS. Otherwise requests are HTTPS only when received over TLS.
+	TrustForwarded bool
+
+	// Cookie settings for double-submit cookies. The cookie is named _csrf, lasts 12 hours, is HTTP only and uses
+	// SameSite=Lax unless set otherwise.
+	CookieName   string
+	CookieDomain string
+	CookiePath   string
+	CookieMaxAge time.Duration
+	Secure       bool
+	SameSite     http.SameSite
+}
+
+// csrfTokenSize is the number of random bytes in a token.
+const csrfTokenSize = 32
+
+// CSRFExempt excludes a route from CSRF checks, such as a webhook authenticated by signature. Passed to
+// RouterGroup.Defaults it excludes every route of the group.
+func CSRFExempt() RouteOption {
+	return func(info *RouteInfo) {
+		info.CSRFExempt = true
+	}
+}
+
+// CSRFToken returns the token to include in forms or request headers of the page being rendered. The token is masked
+// differently for each request so that it cannot be recovered from compressed responses.
+func CSRFToken(ctx context.Context) string {
+	state, ok := ctx.Value(csrfKey).(*csrfState)
+	if !ok {
+		return ""
+	}
+	return maskCSRFToken(state.token)
+}
+
+// CSRFField returns a hidden form input holding the token, for use in templates.
+func CSRFField(ctx context.Context) template.HTML {
+	state, ok := ctx.Value(csrfKey).(*csrfState)
+	if !ok {
+		return ""
+	}
+	return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(state.field) + `" value="` +
+		maskCSRFToken(state.token) + `">`)
+}
+
+// csrfState is the token of a request and the form field it is expected in.
+type csrfState struct {
+	token []byte
+	field string
+}
+
+// CSRF returns middleware which protects state-changing requests from cross-site request forgery. Requests with
+// methods other than GET, HEAD, OPTIONS and TRACE must come from the request's own host or a trusted origin, according
+// to their Origin or Referer header, and must carry the client's token. Failures are rejected with 403 Forbidden.
+func CSRF(opts CSRFOptions) func(http.Handler) http.Handler {
+	if opts.Mode == CSRFSynchronizer && opts.Session == nil {
+		panic("xrouter: CSRF synchronizer tokens require a Session function")
+	}
+	if opts.Store == nil {
+		opts.Store = NewMemoryCSRFStore(12 * time.Hour)
+	}
+	if opts.HeaderName == "" {
+		opts.HeaderName = "X-CSRF-Token"
+	}
+	if opts.FieldName == "" {
+		opts.FieldName = "csrf_token"
+	}
+	if opts.CookieName == "" {
+		opts.CookieName = "_csrf"
+	}
+	if opts.CookiePath == "" {
+		opts.CookiePath = "/"
+	}
+	if opts.CookieMaxAge == 0 {
+		opts.CookieMaxAge = 12 * time.Hour
+	}
+	if opts.SameSite == 0 {
+		opts.SameSite = http.SameSiteLaxMode
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if info, ok := RouteFromContext(r.Context()); ok && info.CSRFExempt {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token := opts.token(w, r)
+			if token != nil {
+				r = r.WithContext(context.WithValue(r.Context(), csrfKey, &csrfState{token, opts.FieldName}))
+			}
+			w.Header().Add("Vary", "Cookie")
+
+			switch r.Method {
+			case "GET", "HEAD", "OPTIONS", "TRACE":
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if reason := opts.checkOrigin(r); reason != "" {
+				WriteError(w, http.StatusForbidden, reason)
+				return
+			}
+			sent := r.Header.Get(opts.HeaderName)
+			if sent == "" {
+				sent = r.PostFormValue(opts.FieldName)
+			}
+			if token == nil || !validCSRFToken(sent, token) {
+				WriteError(w, http.StatusForbidden, "invalid CSRF token")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// token returns the client's token, creating it if the client has none. It returns nil for synchronizer mode requests
+// without a session.
+func (o *CSRFOptions) token(w http.ResponseWriter, r *http.Request) []byte {
+	if o.Mode == CSRFSynchronizer {
+		session := o.Session(r)
+		if session == "" {
+			return nil
+		}
+		if stored, ok := o.Store.Get(session); ok {
+			if token, err := base64.RawURLEncoding.DecodeString(stored); err == nil && len(token) == csrfTokenSize {
+				return token
+			}
+		}
+		token := newCSRFToken()
+		o.Store.Set(session, base64.RawURLEncoding.EncodeToString(token))
+		return token
+	}
+
+	if c, err := r.Cookie(o.CookieName); err == nil {
+		if token := o.verifyCookie(r, c.Value); token != nil {
+			return token
+		}
+	}
+	token := newCSRFToken()
+	http.SetCookie(w, &http.Cookie{
+		Name:     o.CookieName,
+		Value:    o.signCookie(r, token),
+		Domain:   o.CookieDomain,
+		Path:     o.CookiePath,
+		MaxAge:   int(o.CookieMaxAge / time.Second),
+		Secure:   o.Secure,
+		HttpOnly: true,
+		SameSite: o.SameSite,
+	})
+	return token
+}
+
+func (o *CSRFOptions) signCookie(r *http.Request, token []byte) string {
+	value := base64.RawURLEncoding.EncodeToString(token)
+	if o.Secret == nil {
+		return value
+	}
+	return value + "." + base64.RawURLEncoding.EncodeToString(o.mac(r, token))
+}
+
+// verifyCookie returns the token held by a cookie, or nil if it is malformed or its signature is wrong.
+func (o *CSRFOptions) verifyCookie(r *http.Request, value string) []byte {
+	encoded, sig, signed := strings.Cut(value, ".")
+	token, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(token) != csrfTokenSize || signed != (o.Secret != nil) {
+		return nil
+	}
+	if signed {
+		mac, err := base64.RawURLEncoding.DecodeString(sig)
+		if err != nil || !hmac.Equal(mac, o.mac(r, token)) {
+			return nil
+		}
+	}
+	return token
+}
+
+// mac signs a token together with the session of the request, if any.
+func (o *CSRFOptions) mac(r *http.Request, token []byte) []byte {
+	h := hmac.New(sha256.New, o.Secret)
+	if o.Session != nil {
+		session := o.Session(r)
+		io.WriteString(h, strconv.Itoa(len(session))+"!"+session+"!")
+	}
+	h.Write(token)
+	return h.Sum(nil)
+}
+
+// checkOrigin returns why a request does not come from an allowed origin, or an empty string if it does. Origins
+// match by scheme and host, so that a page served over plain HTTP cannot post to the HTTPS site. Requests without
+// Origin and Referer headers are only rejected over HTTPS, where browsers always send one of them.
+func (o *CSRFOptions) checkOrigin(r *http.Request) string {
+	scheme := o.scheme(r)
+	source := r.Header.Get("Origin")
+	if source == "" {
+		source = r.Header.Get("Referer")
+	}
+	if source == "" {
+		if scheme == "https" {
+			return "missing Origin and Referer headers"
+		}
+		return ""
+	}
+	u, err := url.Parse(source)
+	if err != nil || u.Host == "" {
+		return "invalid request origin"
+	}
+	if strings.EqualFold(u.Scheme, scheme) && strings.EqualFold(u.Host, r.Host) {
+		return ""
+	}
+	if slices.ContainsFunc(o.TrustedOrigins, func(origin string) bool {
+		trusted, host, ok := strings.Cut(origin, "://")
+		if !ok {
+			trusted, host = scheme, origin
+		}
+		return strings.EqualFold(u.Scheme, trusted) && strings.EqualFold(u.Host, host)
+	}) {
+		return ""
+	}
+	return "cross-origin request denied"
+}
+
+// scheme returns the scheme the client used for a request.
+func (o *CSRFOptions) scheme(r *http.Request) string {
+	if o.TrustForwarded {
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+			return strings.ToLower(strings.TrimSpace(strings.Split(proto, ",")[0]))
+		}
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+func newCSRFToken() []byte {
+	token := make([]byte, csrfTokenSize)
+	rand.Read(token)
+	return token
+}
+
+// maskCSRFToken encodes a token with a random one-time pad.
+func maskCSRFToken(token []byte) string {
+	masked := make([]byte, 2*len(token))
+	rand.Read(masked[:len(token)])
+	for i, b := range token {
+		masked[len(token)+i] = masked[i] ^ b
+	}
+	return base64.RawURLEncoding.EncodeToString(masked)
+}
+
+// validCSRFToken reports whether a masked token sent by a client matches the token.
+func validCSRFToken(sent string, token []byte) bool {
+	masked, err := base64.RawURLEncoding.DecodeString(sent)
+	if err != nil || len(masked) != 2*len(token) {
+		return false
+	}
+	unmasked := make([]byte, len(token))
+	for i := range unmasked {
+		unmasked[i] = masked[i] ^ masked[len(token)+i]
+	}
+	return subtle.ConstantTimeCompare(unmasked, token) == 1
+}
+
+// MemoryCSRFStore is an in-memory CSRFStore. Tokens expire when unused for the store's lifetime.
+type MemoryCSRFStore struct {
+	lifetime time.Duration
+
+	mu     sync.Mutex
+	tokens map[string]*memoryCSRFToken
+	swept  time.Time
+}
+
+type memoryCSRFToken struct {
+	token   string
+	expires time.Time
+}
+
+// NewMemoryCSRFStore creates a MemoryCSRFStore whose tokens expire after being unused for lifetime.
+func NewMemoryCSRFStore(lifetime time.Duration) *MemoryCSRFStore {
+	return &MemoryCSRFStore{lifetime: lifetime, tokens: make(map[string]*memoryCSRFToken), swept: time.Now()}
+}
+
+// Get returns the unexpired token of a session, extending its lifetime.
+func (s *MemoryCSRFStore) Get(session string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	t, ok := s.tokens[session]
+	if !ok || now.After(t.expires) {
+		return "", false
+	}
+	t.expires = now.Add(s.lifetime)
+	return t.token, true
+}
+
+// Set stores the token of a session, removing expired tokens at most once a minute.
+func (s *MemoryCSRFStore) Set(session, token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.swept) >= time.Minute {
+		for k, t := range s.tokens {
+			if now.After(t.expires) {
+				delete(s.tokens, k)
+			}
+		}
+		s.swept = now
+	}
+	s.tokens[session] = &memoryCSRFToken{token, now.Add(s.lifetime)}
+}
@@ -0,0 +1,196 @@
+package xrouter
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newCSRFRouter(opts CSRFOptions) Router {
+	r := New()
+	r.Use(CSRF(opts))
+	r.GET("/form", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, CSRFToken(ctx))
+	})
+	r.POST("/form", GetTest)
+	r.POST("/webhook", GetTest, CSRFExempt())
+	hooks := r.Group("/hooks")
+	hooks.Defaults(CSRFExempt())
+	hooks.POST("/github", GetTest)
+	return r
+}
+
+// fetchCSRFToken returns the token and cookie issued for a page.
+func fetchCSRFToken(t *testing.T, r Router, header ...string) (string, *http.Cookie) {
+	w := send(r, "GET", "/form", nil, header...)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var cookie *http.Cookie
+	if cookies := w.Result().Cookies(); len(cookies) > 0 {
+		cookie = cookies[0]
+	}
+	return w.Body.String(), cookie
+}
+
+func postCSRF(r Router, path string, cookie *http.Cookie, header ...string) *httptest.ResponseRecorder {
+	if cookie != nil {
+		header = append([]string{"Cookie", cookie.Name + "=" + cookie.Value}, header...)
+	}
+	return send(r, "POST", path, nil, header...)
+}
+
+func TestCSRFDoubleSubmit(t *testing.T) {
+	r := newCSRFRouter(CSRFOptions{})
+	token, cookie := fetchCSRFToken(t, r)
+	if !assert.NotNil(t, cookie) {
+		return
+	}
+	assert.Equal(t, "_csrf", cookie.Name)
+	assert.True(t, cookie.HttpOnly)
+	assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+
+	// The token is masked differently for each page, but every mask is accepted.
+	req := httptest.NewRequest("GET", "/form", nil)
+	req.AddCookie(cookie)
+	w := sendRequest(r, req)
+	assert.Empty(t, w.Result().Cookies())
+	assert.NotEqual(t, token, w.Body.String())
+
+	assert.Equal(t, http.StatusOK, postCSRF(r, "/form", cookie, "X-CSRF-Token", token).Code)
+	assert.Equal(t, http.StatusOK, postCSRF(r, "/form", cookie, "X-CSRF-Token", w.Body.String()).Code)
+
+	form := url.Values{"csrf_token": {token}}.Encode()
+	req = httptest.NewRequest("POST", "/form", strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(cookie)
+	w = sendRequest(r, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.Equal(t, http.StatusForbidden, postCSRF(r, "/form", cookie).Code)
+	assert.Equal(t, http.StatusForbidden, postCSRF(r, "/form", nil, "X-CSRF-Token", token).Code)
+	_, other := fetchCSRFToken(t, r)
+	assert.Equal(t, http.StatusForbidden, postCSRF(r, "/form", other, "X-CSRF-Token", token).Code)
+}
+
+func TestCSRFSignedCookie(t *testing.T) {
+	r := newCSRFRouter(CSRFOptions{Secret: []byte("secret"), CookieName: "xsrf", Secure: true,
+		SameSite: http.SameSiteStrictMode})
+	token, cookie := fetchCSRFToken(t, r)
+	assert.True(t, cookie.Secure)
+	assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+	assert.Equal(t, http.StatusOK, postCSRF(r, "/form", cookie, "X-CSRF-Token", token).Code)
+
+	// A cookie planted without the signature is replaced.
+	planted := &http.Cookie{Name: "xsrf", Value: strings.SplitN(cookie.Value, ".", 2)[0]}
+	w := postCSRF(r, "/form", planted, "X-CSRF-Token", token)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Len(t, w.Result().Cookies(), 1)
+}
+
+func TestCSRFSignedCookieSession(t *testing.T) {
+	r := newCSRFRouter(CSRFOptions{Secret: []byte("secret"),
+		Session: func(r *http.Request) string { return r.Header.Get("X-Session") }})
+	token, cookie := fetchCSRFToken(t, r, "X-Session", "alice")
+	assert.Equal(t, http.StatusOK, postCSRF(r, "/form", cookie, "X-CSRF-Token", token, "X-Session", "alice").Code)
+
+	// A cookie issued to another session, such as one planted by a sibling domain, is rejected.
+	planted, other := fetchCSRFToken(t, r, "X-Session", "mallory")
+	w := postCSRF(r, "/form", other, "X-CSRF-Token", planted, "X-Session", "alice")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Len(t, w.Result().Cookies(), 1)
+}
+
+func TestCSRFOrigin(t *testing.T) {
+	r := newCSRFRouter(CSRFOptions{TrustedOrigins: []string{"https://admin.example.com", "docs.example.com"}})
+	token, cookie := fetchCSRFToken(t, r)
+
+	for origin, status := range map[string]int{
+		"http://example.com":        http.StatusOK,
+		"https://example.com":       http.StatusForbidden,
+		"https://admin.example.com": http.StatusOK,
+		"http://admin.example.com":  http.StatusForbidden,
+		"http://docs.example.com":   http.StatusOK,
+		"https://docs.example.com":  http.StatusForbidden,
+		"https://evil.com":          http.StatusForbidden,
+		"null":                      http.StatusForbidden,
+	} {
+		w := postCSRF(r, "/form", cookie, "X-CSRF-Token", token, "Origin", origin)
+		assert.Equal(t, status, w.Code, origin)
+	}
+	assert.Equal(t, http.StatusOK, postCSRF(r, "/form", cookie, "X-CSRF-Token", token,
+		"Referer", "http://example.com/form").Code)
+	assert.Equal(t, http.StatusForbidden, postCSRF(r, "/form", cookie, "X-CSRF-Token", token,
+		"Referer", "https://evil.com/form").Code)
+
+	// HTTPS requests must say where they come from.
+	req := httptest.NewRequest("POST", "/form", nil)
+	req.TLS = &tls.ConnectionState{}
+	req.AddCookie(cookie)
+	req.Header.Set("X-CSRF-Token", token)
+	w := sendRequest(r, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// A page served over plain HTTP cannot post to the HTTPS site.
+	req.Header.Set("Origin", "http://example.com")
+	w = sendRequest(r, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	req.Header.Set("Origin", "https://example.com")
+	w = sendRequest(r, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Behind a proxy terminating TLS the scheme comes from X-Forwarded-Proto.
+	r = newCSRFRouter(CSRFOptions{TrustForwarded: true})
+	token, cookie = fetchCSRFToken(t, r)
+	assert.Equal(t, http.StatusOK, postCSRF(r, "/form", cookie, "X-CSRF-Token", token,
+		"Origin", "https://example.com", "X-Forwarded-Proto", "https").Code)
+	assert.Equal(t, http.StatusForbidden, postCSRF(r, "/form", cookie, "X-CSRF-Token", token,
+		"Origin", "http://example.com", "X-Forwarded-Proto", "https").Code)
+}
+
+func TestCSRFExempt(t *testing.T) {
+	r := newCSRFRouter(CSRFOptions{})
+	assert.Equal(t, http.StatusOK, postCSRF(r, "/webhook", nil, "Origin", "https://evil.com").Code)
+	assert.Equal(t, http.StatusOK, postCSRF(r, "/hooks/github", nil).Code)
+	assert.Equal(t, http.StatusForbidden, postCSRF(r, "/form", nil).Code)
+
+	info, _, _ := r.Lookup("POST", "/hooks/github")
+	assert.True(t, info.CSRFExempt)
+}
+
+func TestCSRFSynchronizer(t *testing.T) {
+	store := NewMemoryCSRFStore(time.Hour)
+	r := newCSRFRouter(CSRFOptions{
+		Mode:    CSRFSynchronizer,
+		Store:   store,
+		Session: func(r *http.Request) string { return r.Header.Get("X-Session") },
+	})
+
+	token, cookie := fetchCSRFToken(t, r, "X-Session", "alice")
+	assert.Nil(t, cookie)
+	assert.Equal(t, http.StatusOK, postCSRF(r, "/form", nil, "X-CSRF-Token", token, "X-Session", "alice").Code)
+	assert.Equal(t, http.StatusForbidden, postCSRF(r, "/form", nil, "X-CSRF-Token", token, "X-Session", "bob").Code)
+	assert.Equal(t, http.StatusForbidden, postCSRF(r, "/form", nil, "X-CSRF-Token", token).Code)
+	_, ok := store.Get("alice")
+	assert.True(t, ok)
+
+	assert.Panics(t, func() { CSRF(CSRFOptions{Mode: CSRFSynchronizer}) })
+}
+
+func TestCSRFField(t *testing.T) {
+	assert.Equal(t, "", string(CSRFField(context.Background())))
+
+	r := New()
+	r.Use(CSRF(CSRFOptions{FieldName: "_token"}))
+	r.GET("/form", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, string(CSRFField(ctx)))
+	})
+	w := send(r, "GET", "/form", nil)
+	assert.Regexp(t, `^<input type="hidden" name="_token" value="[A-Za-z0-9_-]{86}">$`, w.Body.String())
+}
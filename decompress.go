@@ -0,0 +1,178 @@
+package xrouter
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ErrBodyTooLarge is returned when reading a request body which exceeds the configured limit.
+var ErrBodyTooLarge = errors.New("xrouter: request body too large")
+
+// MaxBodySize returns middleware which limits request bodies to n bytes. Requests which declare a larger
+// Content-Length are rejected immediately. Otherwise reads fail with ErrBodyTooLarge once the limit is exceeded and the
+// response is replaced with a 413 error, as long as the handler has not already written its response.
+func MaxBodySize(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				WriteError(w, http.StatusRequestEntityTooLarge, ErrBodyTooLarge.Error())
+				return
+			}
+			lw, r := limitBody(w, r, r.Body, n)
+			next.ServeHTTP(lw, r)
+			lw.finish()
+		})
+	}
+}
+
+// Decompress returns middleware which transparently decodes request bodies sent with a gzip or deflate
+// Content-Encoding. The decoded body is limited to maxSize bytes to guard against compression bombs, with the same
+// 413 behaviour as MaxBodySize. Unsupported encodings are rejected with 415.
+func Decompress(maxSize int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+			if encoding == "" || encoding == "identity" || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var body io.Reader
+			var err error
+			switch encoding {
+			case "gzip", "x-gzip":
+				body, err = gzip.NewReader(r.Body)
+			case "deflate":
+				body, err = newDeflateReader(r.Body)
+			default:
+				WriteError(w, http.StatusUnsupportedMediaType, "unsupported content encoding: "+encoding)
+				return
+			}
+			if err != nil {
+				WriteError(w, http.StatusBadRequest, "invalid "+encoding+" request body")
+				return
+			}
+
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+			r.ContentLength = -1
+
+			lw, r := limitBody(w, r, body, maxSize)
+			next.ServeHTTP(lw, r)
+			lw.finish()
+		})
+	}
+}
+
+// newDeflateReader decodes a deflate body. The HTTP deflate coding is zlib wrapped, but raw deflate streams are also
+// accepted since many clients send them.
+func newDeflateReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// limitBody replaces the request body with one which fails after n bytes and wraps the writer so that the response
+// becomes a 413 error once the limit is exceeded.
+func limitBody(w http.ResponseWriter, r *http.Request, body io.Reader, n int64) (*limitWriter, *http.Request) {
+	lw := &limitWriter{ResponseWriter: w}
+	r2 := *r
+	r2.Body = &limitedBody{body: body, closer: r.Body, remaining: n, writer: lw}
+	return lw, &r2
+}
+
+type limitedBody struct {
+	body      io.Reader
+	closer    io.Closer
+	remaining int64
+	writer    *limitWriter
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.writer.exceeded {
+		return 0, ErrBodyTooLarge
+	}
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.body.Read(p)
+	if int64(n) > b.remaining {
+		b.writer.exceeded = true
+		return int(b.remaining), ErrBodyTooLarge
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	if b.closer == nil {
+		return nil
+	}
+	return b.closer.Close()
+}
+
+// limitWriter replaces the response with a 413 error if the request body limit was exceeded before the response began.
+type limitWriter struct {
+	http.ResponseWriter
+	exceeded bool
+	started  bool
+	discard  bool
+}
+
+func (w *limitWriter) WriteHeader(code int) {
+	if w.started {
+		return
+	}
+	w.started = true
+	if w.exceeded {
+		w.discard = true
+		WriteError(w.ResponseWriter, http.StatusRequestEntityTooLarge, ErrBodyTooLarge.Error())
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if !w.started {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.discard {
+		return len(p), nil
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// finish writes the 413 error if the handler returned without writing a response after the limit was exceeded.
+func (w *limitWriter) finish() {
+	if w.exceeded && !w.started {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	}
+}
+
+func (w *limitWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok && !w.discard {
+		f.Flush()
+	}
+}
+
+func (w *limitWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// Unwrap returns the underlying ResponseWriter for use with http.ResponseController.
+func (w *limitWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,104 @@
+package xrouter
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func echoBody(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.Write(body)
+}
+
+func compressBody(t *testing.T, encoding, body string) *bytes.Buffer {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	}
+	_, err := w.Write([]byte(body))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	return &buf
+}
+
+func newDecompressRouter() Router {
+	r := New()
+	ingest := r.Group("/ingest")
+	ingest.Use(Decompress(1024))
+	ingest.POST("/", echoBody)
+
+	upload := r.Group("/upload")
+	upload.Use(MaxBodySize(16))
+	upload.POST("/", echoBody)
+	return r
+}
+
+func TestDecompress(t *testing.T) {
+	r := newDecompressRouter()
+	for _, enc := range []string{"gzip", "deflate", "raw"} {
+		encoding := enc
+		if enc == "raw" {
+			encoding = "deflate"
+		}
+		w := send(r, "POST", "/ingest/", compressBody(t, enc, ResponseBody), "Content-Encoding", encoding)
+		assert.Equal(t, http.StatusOK, w.Code, enc)
+		assert.Equal(t, ResponseBody, w.Body.String(), enc)
+	}
+
+	w := send(r, "POST", "/ingest/", strings.NewReader(ResponseBody))
+	assert.Equal(t, ResponseBody, w.Body.String())
+}
+
+func TestDecompressErrors(t *testing.T) {
+	r := newDecompressRouter()
+
+	// A small payload which decodes past the limit.
+	w := send(r, "POST", "/ingest/", compressBody(t, "gzip", strings.Repeat("a", 1<<20)), "Content-Encoding", "gzip")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.JSONEq(t, `{"status":413,"message":"xrouter: request body too large"}`, w.Body.String())
+
+	w = send(r, "POST", "/ingest/", strings.NewReader(ResponseBody), "Content-Encoding", "br")
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+	w = send(r, "POST", "/ingest/", strings.NewReader(ResponseBody), "Content-Encoding", "gzip")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestMaxBodySize(t *testing.T) {
+	r := newDecompressRouter()
+
+	w := send(r, "POST", "/upload/", strings.NewReader(ResponseBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ResponseBody, w.Body.String())
+
+	w = send(r, "POST", "/upload/", strings.NewReader(strings.Repeat("a", 17)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	// Without a Content-Length the limit is enforced while reading.
+	req := httptest.NewRequest("POST", "/upload/", io.NopCloser(strings.NewReader(strings.Repeat("a", 17))))
+	req.ContentLength = -1
+	w = sendRequest(r, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.JSONEq(t, `{"status":413,"message":"xrouter: request body too large"}`, w.Body.String())
+}
@@ -0,0 +1,246 @@
+package xrouter
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/xlog"
+)
+
+// Deprecation describes when a route or API version was deprecated and when it will be removed. Deprecated routes add
+// the Deprecation, Sunset and Link response headers, log a warning for each caller and count their calls.
+type Deprecation struct {
+	// Since is when the route was deprecated. When zero the Deprecation header is "true".
+	Since time.Time
+
+	// Sunset is when the route will stop responding, if known.
+	Sunset time.Time
+
+	// Link is the URL of documentation describing the deprecation.
+	Link string
+
+	// Successor is the URL of the route which replaces the deprecated route.
+	Successor string
+
+	// Message is included in the warnings logged for calls to the route.
+	Message string
+}
+
+// Deprecated marks a route as deprecated. Passed to RouterGroup.Defaults it marks every route of the group.
+func Deprecated(d Deprecation) RouteOption {
+	return func(info *RouteInfo) {
+		info.Deprecation = &d
+	}
+}
+
+// writeHeaders adds the deprecation headers to a response.
+func (d Deprecation) writeHeaders(h http.Header) {
+	if d.Since.IsZero() {
+		h.Set("Deprecation", "true")
+	} else {
+		h.Set("Deprecation", "@"+strconv.FormatInt(d.Since.Unix(), 10))
+	}
+	if !d.Sunset.IsZero() {
+		h.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if d.Link != "" {
+		h.Add("Link", "<"+d.Link+`>; rel="deprecation"`)
+	}
+	if d.Successor != "" {
+		h.Add("Link", "<"+d.Successor+`>; rel="successor-version"`)
+	}
+}
+
+// routeDeprecated reports whether the route handling the request is deprecated.
+func routeDeprecated(ctx context.Context) bool {
+	info, ok := RouteFromContext(ctx)
+	return ok && info.Deprecation != nil
+}
+
+// DeprecatedCalls counts the calls to a deprecated route.
+type DeprecatedCalls struct {
+	Method string
+	Path   string
+
+	// Version is set for calls to a deprecated API version selected by header or media type.
+	Version string
+
+	Calls uint64
+
+	// Callers counts calls by caller. Once maxDeprecatedCallers callers have been seen, further callers are counted
+	// as "other".
+	Callers map[string]uint64
+}
+
+// maxDeprecatedCallers limits the number of callers tracked for each route.
+const maxDeprecatedCallers = 1000
+
+// DeprecationLogInterval sets how often a warning is logged for calls to a deprecated route by the same caller.
+// Defaults to one hour.
+func DeprecationLogInterval(d time.Duration) Option {
+	return func(r *router) {
+		r.group.table.deprecations.interval = d
+	}
+}
+
+// DeprecationCaller sets the function which identifies the caller of a deprecated route, such as by API key or
+// client certificate. Defaults to the client IP address.
+func DeprecationCaller(f func(*http.Request) string) Option {
+	return func(r *router) {
+		r.group.table.deprecations.caller = f
+	}
+}
+
+// DeprecatedCalls returns the number of calls to each deprecated route, by caller.
+func (r *router) DeprecatedCalls() []DeprecatedCalls {
+	return r.group.table.deprecations.report()
+}
+
+// clientIP returns the host of the request's remote address.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// deprecationTracker counts calls to deprecated routes and rate limits the warnings logged for them.
+type deprecationTracker struct {
+	interval time.Duration
+	caller   func(*http.Request) string
+
+	mu     sync.Mutex
+	routes map[string]*deprecatedRoute
+}
+
+type deprecatedRoute struct {
+	method  string
+	path    string
+	version string
+	calls   uint64
+	callers map[string]*deprecatedCaller
+}
+
+type deprecatedCaller struct {
+	calls  uint64
+	logged time.Time
+}
+
+func newDeprecationTracker() *deprecationTracker {
+	return &deprecationTracker{interval: time.Hour, caller: clientIP, routes: make(map[string]*deprecatedRoute)}
+}
+
+// register starts tracking a deprecated route so that it is reported even before it is called.
+func (t *deprecationTracker) register(info RouteInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.route(info.Method, info.Path, "")
+}
+
+// route returns the counts of a route, creating them if needed. The caller must hold the lock.
+func (t *deprecationTracker) route(method, path, version string) *deprecatedRoute {
+	key := method + " " + path + " " + version
+	route, ok := t.routes[key]
+	if !ok {
+		route = &deprecatedRoute{method: method, path: path, version: version,
+			callers: make(map[string]*deprecatedCaller)}
+		t.routes[key] = route
+	}
+	return route
+}
+
+// handler adds the deprecation headers to responses of the route, counts its calls and logs a warning the first time
+// each caller calls it within the log interval.
+func (t *deprecationTracker) handler(info *RouteInfo, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info.Deprecation.writeHeaders(w.Header())
+		t.track(r, info.Method, info.Path, "", info.Deprecation)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// versions makes the tracker available to versioned routes, which only know the version being called, and so whether
+// it is deprecated, once the request is handled.
+func (t *deprecationTracker) versions(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), deprecationKey, t)))
+	})
+}
+
+// track counts a call to a deprecated route or API version and logs a warning the first time each caller calls it
+// within the log interval.
+func (t *deprecationTracker) track(r *http.Request, method, path, version string, d *Deprecation) {
+	caller := t.caller(r)
+	if !t.record(method, path, version, caller) {
+		return
+	}
+	fields := xlog.F{"method": method, "path": path, "caller": caller}
+	if version != "" {
+		fields["version"] = version
+	}
+	if !d.Sunset.IsZero() {
+		fields["sunset"] = d.Sunset.UTC().Format(time.RFC3339)
+	}
+	if d.Message != "" {
+		fields["deprecation"] = d.Message
+	}
+	xlog.FromContext(r.Context()).Warn("deprecated route called", fields)
+}
+
+// record counts a call and reports whether a warning should be logged for it.
+func (t *deprecationTracker) record(method, path, version, caller string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	route := t.route(method, path, version)
+	route.calls++
+	c, ok := route.callers[caller]
+	if !ok {
+		if len(route.callers) >= maxDeprecatedCallers {
+			caller = "other"
+		}
+		if c, ok = route.callers[caller]; !ok {
+			c = &deprecatedCaller{}
+			route.callers[caller] = c
+		}
+	}
+	c.calls++
+
+	now := time.Now()
+	if now.Sub(c.logged) < t.interval {
+		return false
+	}
+	c.logged = now
+	return true
+}
+
+// report returns the call counts of every deprecated route, sorted by path, method and version.
+func (t *deprecationTracker) report() []DeprecatedCalls {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	calls := make([]DeprecatedCalls, 0, len(t.routes))
+	for _, route := range t.routes {
+		callers := make(map[string]uint64, len(route.callers))
+		for name, c := range route.callers {
+			callers[name] = c.calls
+		}
+		calls = append(calls, DeprecatedCalls{route.method, route.path, route.version, route.calls, callers})
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		if calls[i].Path != calls[j].Path {
+			return calls[i].Path < calls[j].Path
+		}
+		if calls[i].Method != calls[j].Method {
+			return calls[i].Method < calls[j].Method
+		}
+		return calls[i].Version < calls[j].Version
+	})
+	return calls
+}
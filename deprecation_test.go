@@ -0,0 +1,121 @@
+package xrouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+// logCapture records the messages written by xlog.
+type logCapture struct {
+	mu       sync.Mutex
+	messages []map[string]interface{}
+}
+
+func (c *logCapture) Write(fields map[string]interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = append(c.messages, fields)
+	return nil
+}
+
+func (c *logCapture) warnings() []map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var warnings []map[string]interface{}
+	for _, m := range c.messages {
+		if m[xlog.KeyLevel] == "warn" {
+			warnings = append(warnings, m)
+		}
+	}
+	return warnings
+}
+
+func newDeprecatedRouter(logs *logCapture, opts ...Option) Router {
+	r := New(opts...)
+	r.Use(xlog.NewHandler(xlog.Config{Output: logs}))
+
+	v1 := r.Group("/v1")
+	v1.Defaults(Deprecated(Deprecation{
+		Since:     time.Unix(1700000000, 0),
+		Sunset:    sunset,
+		Successor: "/v2/users",
+		Message:   "use v2",
+	}))
+	v1.GET("/users", GetTest)
+	v1.GET("/teams", GetTest, Deprecated(Deprecation{Link: "https://example.com/teams"}))
+	r.GET("/v2/users", GetTest)
+	return r
+}
+
+func callDeprecated(r Router, path, remote string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	req.RemoteAddr = remote + ":1234"
+	return sendRequest(r, req)
+}
+
+func TestDeprecationHeaders(t *testing.T) {
+	r := newDeprecatedRouter(&logCapture{})
+
+	w := callDeprecated(r, "/v1/users", "10.0.0.1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "@1700000000", w.Header().Get("Deprecation"))
+	assert.Equal(t, "Fri, 01 Jan 2027 00:00:00 GMT", w.Header().Get("Sunset"))
+	assert.Equal(t, `</v2/users>; rel="successor-version"`, w.Header().Get("Link"))
+
+	w = callDeprecated(r, "/v1/teams", "10.0.0.1")
+	assert.Equal(t, "true", w.Header().Get("Deprecation"))
+	assert.Empty(t, w.Header().Get("Sunset"))
+	assert.Equal(t, `<https://example.com/teams>; rel="deprecation"`, w.Header().Get("Link"))
+
+	w = callDeprecated(r, "/v2/users", "10.0.0.1")
+	assert.Empty(t, w.Header().Get("Deprecation"))
+}
+
+func TestDeprecationWarningsAreRateLimited(t *testing.T) {
+	logs := &logCapture{}
+	r := newDeprecatedRouter(logs)
+
+	callDeprecated(r, "/v1/users", "10.0.0.1")
+	callDeprecated(r, "/v1/users", "10.0.0.1")
+	callDeprecated(r, "/v1/users", "10.0.0.2")
+	callDeprecated(r, "/v2/users", "10.0.0.1")
+
+	warnings := logs.warnings()
+	if assert.Len(t, warnings, 2) {
+		assert.Equal(t, "10.0.0.1", warnings[0]["caller"])
+		assert.Equal(t, "/v1/users", warnings[0]["path"])
+		assert.Equal(t, "use v2", warnings[0]["deprecation"])
+		assert.Equal(t, "2027-01-01T00:00:00Z", warnings[0]["sunset"])
+		assert.Equal(t, "10.0.0.2", warnings[1]["caller"])
+	}
+
+	logs = &logCapture{}
+	r = newDeprecatedRouter(logs, DeprecationLogInterval(0), DeprecationCaller(func(r *http.Request) string {
+		return r.Header.Get("X-API-Key")
+	}))
+	callDeprecated(r, "/v1/users", "10.0.0.1")
+	callDeprecated(r, "/v1/users", "10.0.0.1")
+	assert.Len(t, logs.warnings(), 2)
+}
+
+func TestDeprecatedCalls(t *testing.T) {
+	r := newDeprecatedRouter(&logCapture{})
+
+	callDeprecated(r, "/v1/users", "10.0.0.1")
+	callDeprecated(r, "/v1/users", "10.0.0.1")
+	callDeprecated(r, "/v1/users", "10.0.0.2")
+
+	assert.Equal(t, []DeprecatedCalls{
+		{Method: "GET", Path: "/v1/teams", Calls: 0, Callers: map[string]uint64{}},
+		{Method: "GET", Path: "/v1/users", Calls: 3, Callers: map[string]uint64{"10.0.0.1": 2, "10.0.0.2": 1}},
+	}, r.DeprecatedCalls())
+
+	info, _, _ := r.Lookup("GET", "/v1/users")
+	assert.Equal(t, "/v2/users", info.Deprecation.Successor)
+}
@@ -9,10 +9,58 @@ type AddHandlerEvent struct {
 	Path   string
 }
 
-// NotFoundHandlerEvent is fired when a NotFound handler is set for the router.
+// NotFoundHandlerEvent is fired when a NotFound handler is set for the router or a group.
 type NotFoundHandlerEvent struct {
+	// Group is the prefix of the group which set the handler, or empty for the router.
+	Group string
 }
 
-// MethodNotAllowedHandlerEvent is fired when a MethodNotAllowed handler is set for the router.
+// MethodNotAllowedHandlerEvent is fired when a MethodNotAllowed handler is set for the router or a group.
 type MethodNotAllowedHandlerEvent struct {
+	// Group is the prefix of the group which set the handler, or empty for the router.
+	Group string
+}
+
+// RouteErrorEvent is fired when a route fails to register, such as when it conflicts with an existing route.
+type RouteErrorEvent struct {
+	Err *RouteError
+}
+
+// ServerStartEvent is fired when the server starts accepting requests.
+type ServerStartEvent struct {
+	Network string
+	Addr    string
+}
+
+// ServerShutdownEvent is fired when a graceful shutdown begins.
+type ServerShutdownEvent struct {
+}
+
+// ServerStopEvent is fired when the server has stopped. Err is nil after a clean shutdown.
+type ServerStopEvent struct {
+	Err error
+}
+
+// CircuitStateEvent is fired when a circuit of a CircuitBreaker changes state. Route is empty for circuits shared by
+// every route.
+type CircuitStateEvent struct {
+	Name  string
+	Route string
+	From  CircuitState
+	To    CircuitState
+}
+
+// BulkheadRejectedEvent is fired when a Bulkhead rejects a request because its queue is full, the request waited too
+// long or the client went away.
+type BulkheadRejectedEvent struct {
+	Name   string
+	Route  string
+	Reason string
+}
+
+// LoadShedEvent is fired when a LoadShedder rejects a request.
+type LoadShedEvent struct {
+	Name     string
+	Route    string
+	Priority Priority
 }
@@ -0,0 +1,79 @@
+package xrouter
+
+import (
+	"net/http"
+	"strings"
+)
+
+// scopedHandler is a NotFound or MethodNotAllowed handler which applies to requests under a group's prefix.
+type scopedHandler struct {
+	prefix  string
+	handler http.Handler
+}
+
+// fallbacks holds the NotFound and MethodNotAllowed handlers set by the router and its groups.
+type fallbacks struct {
+	notFound         []scopedHandler
+	methodNotAllowed []scopedHandler
+}
+
+// setScoped replaces the handler for the prefix or adds it.
+func setScoped(handlers []scopedHandler, prefix string, h http.Handler) []scopedHandler {
+	for i := range handlers {
+		if handlers[i].prefix == prefix {
+			handlers[i].handler = h
+			return handlers
+		}
+	}
+	return append(handlers, scopedHandler{prefix, h})
+}
+
+// selectScoped returns the handler with the longest prefix containing the path.
+func selectScoped(handlers []scopedHandler, path string) http.Handler {
+	var best *scopedHandler
+	for i, s := range handlers {
+		if s.prefix != "" && path != s.prefix && !strings.HasPrefix(path, strings.TrimSuffix(s.prefix, "/")+"/") {
+			continue
+		}
+		if best == nil || len(s.prefix) > len(best.prefix) {
+			best = &handlers[i]
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return best.handler
+}
+
+// dispatchNotFound serves unmatched requests with the most specific NotFound handler.
+func (f *fallbacks) dispatchNotFound(w http.ResponseWriter, req *http.Request) {
+	if h := selectScoped(f.notFound, req.URL.Path); h != nil {
+		h.ServeHTTP(w, req)
+		return
+	}
+	http.NotFound(w, req)
+}
+
+// dispatchMethodNotAllowed serves requests for the wrong method with the most specific MethodNotAllowed handler.
+func (f *fallbacks) dispatchMethodNotAllowed(w http.ResponseWriter, req *http.Request) {
+	if h := selectScoped(f.methodNotAllowed, req.URL.Path); h != nil {
+		h.ServeHTTP(w, req)
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
+
+// NotFound sets the handler for unmatched requests under the group's prefix. The handler runs with the group's
+// middleware, and the group with the longest matching prefix handles each request.
+func (r *routerGroup) NotFound(h http.Handler) {
+	r.table.fallbacks.notFound = setScoped(r.table.fallbacks.notFound, r.prefix, r.live(h))
+	r.router.NotFound = http.HandlerFunc(r.table.fallbacks.dispatchNotFound)
+	r.evtHandler(NotFoundHandlerEvent{Group: r.prefix})
+}
+
+// MethodNotAllowed sets the handler for requests under the group's prefix which match a route for another method.
+func (r *routerGroup) MethodNotAllowed(h http.Handler) {
+	r.table.fallbacks.methodNotAllowed = setScoped(r.table.fallbacks.methodNotAllowed, r.prefix, r.live(h))
+	r.router.MethodNotAllowed = http.HandlerFunc(r.table.fallbacks.dispatchMethodNotAllowed)
+	r.evtHandler(MethodNotAllowedHandlerEvent{Group: r.prefix})
+}
@@ -0,0 +1,98 @@
+package xrouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func textHandler(status int, body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	})
+}
+
+func headerMiddleware(name, value string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(name, value)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestGroupNotFound(t *testing.T) {
+	var events []Event
+	r := New()
+	r.EventHandler(func(evt Event) { events = append(events, evt) })
+	r.NotFound(textHandler(http.StatusNotFound, "html"))
+
+	api := r.Group("/api")
+	api.Use(headerMiddleware("X-Group", "api"))
+	api.GET("/users", GetTest)
+	api.NotFound(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, http.StatusNotFound, "not found")
+	}))
+
+	v2 := api.Group("/v2")
+	v2.NotFound(textHandler(http.StatusNotFound, "v2"))
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.Handler().ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	w := serve("/api/missing")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"status":404,"message":"not found"}`, w.Body.String())
+	assert.Equal(t, "api", w.Header().Get("X-Group"))
+
+	assert.Equal(t, "v2", serve("/api/v2/missing").Body.String())
+	assert.Equal(t, "v2", serve("/api/v2").Body.String())
+	assert.Equal(t, "html", serve("/apiary").Body.String())
+	assert.Equal(t, "html", serve("/missing").Body.String())
+	assert.Empty(t, serve("/missing").Header().Get("X-Group"))
+
+	assert.Contains(t, events, NotFoundHandlerEvent{})
+	assert.Contains(t, events, NotFoundHandlerEvent{Group: "/api"})
+	assert.Contains(t, events, NotFoundHandlerEvent{Group: "/api/v2"})
+}
+
+func TestGroupNotFoundWithoutRootHandler(t *testing.T) {
+	r := New()
+	r.Group("/api").NotFound(textHandler(http.StatusNotFound, "api"))
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/other", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "404 page not found\n", w.Body.String())
+}
+
+func TestGroupMethodNotAllowed(t *testing.T) {
+	var events []Event
+	r := New()
+	r.EventHandler(func(evt Event) { events = append(events, evt) })
+	r.GET("/home", GetTest)
+
+	api := r.Group("/api")
+	api.GET("/users", GetTest)
+	api.MethodNotAllowed(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}))
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("POST", "/api/users", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.JSONEq(t, `{"status":405,"message":"method not allowed"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("POST", "/home", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "Method Not Allowed\n", w.Body.String())
+
+	assert.Contains(t, events, MethodNotAllowedHandlerEvent{Group: "/api"})
+}
@@ -3,83 +3,143 @@ package xrouter
 import (
 	"net/http"
 	"path/filepath"
+	"slices"
 
 	"github.com/julienschmidt/httprouter"
-	"github.com/justinas/alice"
 )
 
-func newGroup(prefix string, chain alice.Chain, r *httprouter.Router, evtHandler func(evt Event)) *routerGroup {
-	return &routerGroup{prefix, chain, r, evtHandler}
+func newGroup(prefix string, parent *routerGroup, r *httprouter.Router, table *routeTable, evtHandler func(evt Event)) *routerGroup {
+	return &routerGroup{prefix: prefix, parent: parent, router: r, table: table, evtHandler: evtHandler}
 }
 
-func wrapper(chain alice.Chain, f Route) http.Handler {
-	return chain.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+func wrapper(f Route) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		f(r.Context(), w, r)
 	})
 }
 
 type routerGroup struct {
 	prefix     string
-	chain      alice.Chain
+	parent     *routerGroup
+	ops        []chainOp
 	router     *httprouter.Router
+	table      *routeTable
 	evtHandler func(evt Event)
+	defaults   []RouteOption
 }
 
-// Use adds middleware to the router.
-func (r *routerGroup) Use(f func(next http.Handler) http.Handler) {
-	r.chain = r.chain.Append(f)
+// Handle adds a handler for the given method and path.
+func (r *routerGroup) Handle(method, path string, handler Route, opts ...RouteOption) {
+	pattern, constraints, cerr := parseConstraints(r.prefix + path)
+	info := RouteInfo{Method: method, Path: pattern, Group: r.prefix, Source: caller(), Constraints: constraints,
+		Middleware: r.middlewareNames()}
+	for _, opt := range r.routeDefaults() {
+		opt(&info)
+	}
+	for _, opt := range opts {
+		opt(&info)
+	}
+
+	var err *RouteError
+	if cerr != nil {
+		info.Path = r.prefix + path
+		err = &RouteError{Route: info, Reason: cerr.Error()}
+	} else if h := httpParamsHandler(&info, r.live(r.deprecate(&info, wrapper(handler)))); len(constraints) > 0 {
+		err = r.register(info, r.constrain(constraints, h))
+	} else {
+		err = r.register(info, h)
+	}
+	if err != nil {
+		r.evtHandler(RouteErrorEvent{err})
+		if !r.table.collect {
+			panic(err)
+		}
+		r.table.errors = append(r.table.errors, err)
+		return
+	}
+	r.table.add(info, r)
+	if info.Deprecation != nil {
+		r.table.deprecations.register(info)
+	}
+	r.evtHandler(AddHandlerEvent{method, info.Path})
 }
 
-// Chain gets the middleware chain.
-func (r *routerGroup) Chain() alice.Chain {
-	return r.chain
+// deprecate adds the deprecation headers, warnings and call counts to deprecated routes. Versioned routes which may
+// serve a deprecated version are given the tracker to count those calls.
+func (r *routerGroup) deprecate(info *RouteInfo, h http.Handler) http.Handler {
+	switch {
+	case info.Deprecation != nil:
+		return r.table.deprecations.handler(info, h)
+	case len(info.Versions) > 0:
+		return r.table.deprecations.versions(h)
+	}
+	return h
 }
 
 // GET adds a GET handler at the given path.
-func (r *routerGroup) GET(path string, handler Route) {
-	r.router.GET(r.prefix+path, httpParamsHandler(r.chain, handler))
-	r.evtHandler(AddHandlerEvent{"GET", r.prefix + path})
+func (r *routerGroup) GET(path string, handler Route, opts ...RouteOption) {
+	r.Handle("GET", path, handler, opts...)
 }
 
 // POST adds a POST handler at the given path.
-func (r *routerGroup) POST(path string, handler Route) {
-	r.router.POST(r.prefix+path, httpParamsHandler(r.chain, handler))
-	r.evtHandler(AddHandlerEvent{"POST", r.prefix + path})
+func (r *routerGroup) POST(path string, handler Route, opts ...RouteOption) {
+	r.Handle("POST", path, handler, opts...)
 }
 
 // PUT adds a PUT handler at the given path.
-func (r *routerGroup) PUT(path string, handler Route) {
-	r.router.PUT(r.prefix+path, httpParamsHandler(r.chain, handler))
-	r.evtHandler(AddHandlerEvent{"PUT", r.prefix + path})
+func (r *routerGroup) PUT(path string, handler Route, opts ...RouteOption) {
+	r.Handle("PUT", path, handler, opts...)
 }
 
 // OPTIONS adds a OPTIONS handler at the given path.
-func (r *routerGroup) OPTIONS(path string, handler Route) {
-	r.router.OPTIONS(r.prefix+path, httpParamsHandler(r.chain, handler))
-	r.evtHandler(AddHandlerEvent{"OPTIONS", r.prefix + path})
+func (r *routerGroup) OPTIONS(path string, handler Route, opts ...RouteOption) {
+	r.Handle("OPTIONS", path, handler, opts...)
 }
 
 // HEAD adds a HEAD handler at the given path.
-func (r *routerGroup) HEAD(path string, handler Route) {
-	r.router.HEAD(r.prefix+path, httpParamsHandler(r.chain, handler))
-	r.evtHandler(AddHandlerEvent{"HEAD", r.prefix + path})
+func (r *routerGroup) HEAD(path string, handler Route, opts ...RouteOption) {
+	r.Handle("HEAD", path, handler, opts...)
 }
 
 // PATCH adds a PATCH handler at the given path.
-func (r *routerGroup) PATCH(path string, handler Route) {
-	r.router.PATCH(r.prefix+path, httpParamsHandler(r.chain, handler))
-	r.evtHandler(AddHandlerEvent{"PATCH", r.prefix + path})
+func (r *routerGroup) PATCH(path string, handler Route, opts ...RouteOption) {
+	r.Handle("PATCH", path, handler, opts...)
 }
 
 // DELETE adds a DELETE handler at the given path.
-func (r *routerGroup) DELETE(path string, handler Route) {
-	r.router.DELETE(r.prefix+path, httpParamsHandler(r.chain, handler))
-	r.evtHandler(AddHandlerEvent{"DELETE", r.prefix + path})
+func (r *routerGroup) DELETE(path string, handler Route, opts ...RouteOption) {
+	r.Handle("DELETE", path, handler, opts...)
+}
+
+// WebSocket adds a WebSocket handler at the given path. Routes without a WebSocketConfig option use the defaults.
+func (r *routerGroup) WebSocket(path string, handler WebSocketHandler, opts ...RouteOption) {
+	opts = append([]RouteOption{WebSocketConfig(WebSocketOptions{})}, opts...)
+	r.Handle("GET", path, WebSocketRoute(handler), opts...)
 }
 
 // Group returns a new router which strips the given path before the request is handled. All the middleware from the router is transferred.
 func (r *routerGroup) Group(path string) RouterGroup {
-	return newGroup(r.prefix+path, r.chain.Append(), r.router, r.evtHandler)
+	return newGroup(r.prefix+path, r, r.router, r.table, r.evtHandler)
+}
+
+// Defaults adds options which are applied to every route later registered with the group or its child groups,
+// including child groups created before. The route's own options are applied afterwards and can override them. Unlike
+// middleware, defaults are resolved when a route is registered, so routes registered earlier are unchanged.
+func (r *routerGroup) Defaults(opts ...RouteOption) {
+	r.defaults = append(r.defaults, opts...)
+}
+
+// routeDefaults returns the defaults of the group's parents followed by its own.
+func (r *routerGroup) routeDefaults() []RouteOption {
+	if r.parent == nil {
+		return r.defaults
+	}
+	return append(slices.Clone(r.parent.routeDefaults()), r.defaults...)
+}
+
+// ShuttingDown returns true once the router has begun a graceful shutdown.
+func (r *routerGroup) ShuttingDown() bool {
+	return r.table.shuttingDown.Load()
 }
 
 func (r *routerGroup) Path() string {
@@ -0,0 +1,250 @@
+// Package health provides /healthz, /readyz and /livez endpoints backed by named checks.
+//
+// Liveness checks report whether the process should be restarted, readiness checks report whether it should receive
+// traffic and /healthz reports both. Readiness fails automatically once the router begins a graceful shutdown.
+// Adding ?verbose to a request returns the result of each check as JSON.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eliquious/xrouter"
+)
+
+// DefaultTimeout is the timeout used for checks which do not set their own.
+const DefaultTimeout = 5 * time.Second
+
+// ErrShuttingDown is reported by the readiness endpoint during a graceful shutdown.
+var ErrShuttingDown = errors.New("shutting down")
+
+// Checker checks the health of a dependency and returns an error if it is unhealthy.
+type Checker func(ctx context.Context) error
+
+// CheckOption configures a check.
+type CheckOption func(*check)
+
+// Timeout sets how long the check may run before it is considered failed.
+func Timeout(d time.Duration) CheckOption {
+	return func(c *check) {
+		c.timeout = d
+	}
+}
+
+// CacheFor reuses the result of the check for the given duration instead of running it on every request.
+func CacheFor(d time.Duration) CheckOption {
+	return func(c *check) {
+		c.ttl = d
+	}
+}
+
+type check struct {
+	name    string
+	checker Checker
+	timeout time.Duration
+	ttl     time.Duration
+
+	mu      sync.Mutex
+	last    Result
+	checked time.Time
+	running *checkRun
+}
+
+// checkRun is an execution of a check shared by every probe which arrives while it is in flight.
+type checkRun struct {
+	done chan struct{}
+	res  Result
+}
+
+// run returns the result of the check, using the cached result if it is still fresh. Concurrent probes share a single
+// execution instead of queueing up behind each other, and each one stops waiting when its own context ends.
+func (c *check) run(ctx context.Context) Result {
+	start := time.Now()
+	c.mu.Lock()
+	if c.ttl > 0 && !c.checked.IsZero() && time.Since(c.checked) < c.ttl {
+		res := c.last
+		c.mu.Unlock()
+		res.Cached = true
+		return res
+	}
+	run := c.running
+	if run == nil {
+		run = &checkRun{done: make(chan struct{})}
+		c.running = run
+		go c.execute(context.WithoutCancel(ctx), run)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-run.done:
+		return run.res
+	case <-ctx.Done():
+		return c.result(start, ctx.Err())
+	}
+}
+
+// execute runs the checker within the check's timeout and publishes the result to the waiting probes.
+func (c *check) execute(ctx context.Context, run *checkRun) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.checker(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	run.res = c.result(start, err)
+
+	c.mu.Lock()
+	c.last, c.checked, c.running = run.res, time.Now(), nil
+	c.mu.Unlock()
+	close(run.done)
+}
+
+func (c *check) result(start time.Time, err error) Result {
+	res := Result{Name: c.name, Status: "ok", Duration: time.Since(start).String()}
+	if err != nil {
+		res.Status = "failed"
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// Result is the outcome of a single check as reported in verbose output.
+type Result struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+	Cached   bool   `json:"cached,omitempty"`
+}
+
+// Report is the verbose output of an endpoint.
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// Health holds the registered checks.
+type Health struct {
+	mu        sync.RWMutex
+	liveness  []*check
+	readiness []*check
+
+	shuttingDown atomic.Bool
+	group        xrouter.RouterGroup
+}
+
+// New creates an empty set of checks.
+func New() *Health {
+	return &Health{}
+}
+
+func newCheck(name string, checker Checker, opts []CheckOption) *check {
+	c := &check{name: name, checker: checker, timeout: DefaultTimeout}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Liveness adds a check which is reported by /livez and /healthz.
+func (h *Health) Liveness(name string, checker Checker, opts ...CheckOption) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.liveness = append(h.liveness, newCheck(name, checker, opts))
+}
+
+// Readiness adds a check which is reported by /readyz and /healthz.
+func (h *Health) Readiness(name string, checker Checker, opts ...CheckOption) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.readiness = append(h.readiness, newCheck(name, checker, opts))
+}
+
+// MarkShuttingDown makes the readiness endpoint fail. It is only needed when the server is not run by Router.Serve.
+func (h *Health) MarkShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// ShuttingDown returns true once a shutdown has been signalled by MarkShuttingDown or by the router.
+func (h *Health) ShuttingDown() bool {
+	return h.shuttingDown.Load() || (h.group != nil && h.group.ShuttingDown())
+}
+
+// Register adds the /healthz, /readyz and /livez routes to the group. The routes are silent so they are excluded from
+// access logs and metrics. Readiness fails once the router serving the group begins shutting down.
+func (h *Health) Register(group xrouter.RouterGroup) {
+	h.group = group
+	group.GET("/healthz", h.handler(true, true), xrouter.Silent(), xrouter.Summary("Health check"))
+	group.GET("/readyz", h.handler(false, true), xrouter.Silent(), xrouter.Summary("Readiness check"))
+	group.GET("/livez", h.handler(true, false), xrouter.Silent(), xrouter.Summary("Liveness check"))
+}
+
+// Check runs the selected checks concurrently and returns the combined report.
+func (h *Health) Check(ctx context.Context, liveness, readiness bool) Report {
+	h.mu.RLock()
+	var checks []*check
+	if liveness {
+		checks = append(checks, h.liveness...)
+	}
+	if readiness {
+		checks = append(checks, h.readiness...)
+	}
+	h.mu.RUnlock()
+
+	report := Report{Status: "ok", Checks: make([]Result, len(checks))}
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c *check) {
+			defer wg.Done()
+			report.Checks[i] = c.run(ctx)
+		}(i, c)
+	}
+	wg.Wait()
+
+	if readiness && h.ShuttingDown() {
+		report.Checks = append(report.Checks, Result{Name: "shutdown", Status: "failed", Error: ErrShuttingDown.Error()})
+	}
+	for _, res := range report.Checks {
+		if res.Status != "ok" {
+			report.Status = "failed"
+		}
+	}
+	return report
+}
+
+func (h *Health) handler(liveness, readiness bool) xrouter.Route {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		report := h.Check(ctx, liveness, readiness)
+
+		status := http.StatusOK
+		if report.Status != "ok" {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Cache-Control", "no-store")
+
+		if _, verbose := r.URL.Query()["verbose"]; verbose {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(report)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		w.Write([]byte(report.Status + "\n"))
+	}
+}
@@ -0,0 +1,130 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eliquious/xrouter"
+	"github.com/eliquious/xrouter/xroutertest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	var dbErr error
+	r := xrouter.New()
+	h := New()
+	h.Liveness("deadlock", func(ctx context.Context) error { return nil })
+	h.Readiness("db", func(ctx context.Context) error { return dbErr })
+	h.Register(r)
+
+	c := xroutertest.New(t, r)
+	c.GET("/healthz").Do().Status(http.StatusOK).BodyEquals("ok\n")
+	c.GET("/readyz").Do().Status(http.StatusOK)
+	c.GET("/livez").Do().Status(http.StatusOK)
+
+	dbErr = errors.New("connection refused")
+	c.GET("/livez").Do().Status(http.StatusOK)
+	c.GET("/healthz").Do().Status(http.StatusServiceUnavailable).BodyEquals("failed\n")
+	c.GET("/readyz?verbose").Do().
+		Status(http.StatusServiceUnavailable).
+		JSONPath("status", "failed").
+		JSONPath("checks.0.name", "db").
+		JSONPath("checks.0.error", "connection refused")
+
+	info, _, ok := r.Lookup("GET", "/healthz")
+	assert.True(t, ok)
+	assert.True(t, info.Silent)
+	assert.Contains(t, info.Source, "health_test.go:")
+}
+
+func TestHealthTimeoutAndCache(t *testing.T) {
+	calls := 0
+	h := New()
+	h.Readiness("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, Timeout(10*time.Millisecond))
+	h.Liveness("cached", func(ctx context.Context) error {
+		calls++
+		return nil
+	}, CacheFor(time.Minute))
+
+	report := h.Check(context.Background(), false, true)
+	assert.Equal(t, "failed", report.Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks[0].Error)
+
+	h.Check(context.Background(), true, false)
+	report = h.Check(context.Background(), true, false)
+	assert.Equal(t, "ok", report.Status)
+	assert.True(t, report.Checks[0].Cached)
+	assert.Equal(t, 1, calls)
+}
+
+func TestHealthConcurrentProbes(t *testing.T) {
+	var calls atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	h := New()
+	h.Readiness("db", func(ctx context.Context) error {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	probe := func() {
+		defer wg.Done()
+		assert.Equal(t, "ok", h.Check(context.Background(), false, true).Status)
+	}
+	wg.Add(1)
+	go probe()
+	<-started
+
+	// A probe which gives up does not wait for the check in flight.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	report := h.Check(ctx, false, true)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks[0].Error)
+
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go probe()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.EqualValues(t, 1, calls.Load())
+}
+
+func TestHealthShutdown(t *testing.T) {
+	r := xroutertest.NewFakeRouter()
+	h := New()
+	h.Register(r)
+	c := xroutertest.New(t, r)
+
+	c.GET("/readyz").Do().Status(http.StatusOK)
+	r.Draining = true
+	c.GET("/readyz?verbose").Do().
+		Status(http.StatusServiceUnavailable).
+		JSONPath("checks.0.name", "shutdown")
+	c.GET("/livez").Do().Status(http.StatusOK)
+
+	// Registering on a group follows the shutdown of its router.
+	r = xroutertest.NewFakeRouter()
+	h = New()
+	h.Register(r.Group("/internal"))
+	c = xroutertest.New(t, r)
+	c.GET("/internal/readyz").Do().Status(http.StatusOK)
+	r.Draining = true
+	c.GET("/internal/readyz").Do().Status(http.StatusServiceUnavailable)
+
+	h = New()
+	h.MarkShuttingDown()
+	assert.Equal(t, "failed", h.Check(context.Background(), false, true).Status)
+}
@@ -0,0 +1,279 @@
+package xrouter
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IdempotencyKeyHeader is the request header which carries the idempotency key of a request.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader is set to "true" on responses replayed from the IdempotencyStore.
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// maxIdempotencyKey is the length of the longest idempotency key accepted.
+const maxIdempotencyKey = 255
+
+// IdempotencyRecord is the state of an idempotency key held by an IdempotencyStore.
+type IdempotencyRecord struct {
+	// Fingerprint identifies the request which first used the key.
+	Fingerprint string
+
+	// Response is the response to that request, or nil while it is being handled.
+	Response *CachedResponse
+}
+
+// IdempotencyStore holds the responses of requests with idempotency keys. Implementations shared between servers must
+// make Reserve atomic.
+type IdempotencyStore interface {
+	// Reserve claims an unused key for a request with the fingerprint until it completes or the ttl expires. The token
+	// identifies the reservation to Complete and Release. If the key is in use its record is returned with false.
+	Reserve(key, fingerprint, token string, ttl time.Duration) (*IdempotencyRecord, bool)
+
+	// Complete stores the response to the request which reserved the key for the given duration. It does nothing if
+	// the reservation with the token has expired and the key was reserved again.
+	Complete(key, token string, resp *CachedResponse, ttl time.Duration)
+
+	// Release frees a key reserved with the token so that the request can be retried.
+	Release(key, token string)
+}
+
+// IdempotencyOptions configures the Idempotency middleware.
+type IdempotencyOptions struct {
+	// Store holds the responses. Defaults to a MemoryIdempotencyStore.
+	Store IdempotencyStore
+
+	// TTL is how long responses are replayed for. Defaults to 24 hours.
+	TTL time.Duration
+
+	// LockTimeout is how long a key stays reserved if the request holding it never completes. Defaults to one minute.
+	LockTimeout time.Duration
+
+	// Methods lists the methods which honour idempotency keys. Defaults to POST and PATCH.
+	Methods []string
+
+	// Required rejects requests without an idempotency key with 400 Bad Request.
+	Required bool
+
+	// MaxBodySize limits the bodies of requests with idempotency keys, which are read into memory to fingerprint them.
+	// Larger requests are rejected with 413 Request Entity Too Large. Defaults to 1 MiB.
+	MaxBodySize int64
+
+	// Scope returns a namespace for the keys of a request, such as the authenticated user, so that clients cannot see
+	// each other's responses. Defaults to the request's credentials, its Authorization and Cookie headers, so a client
+	// whose cookies change between retries should set a Scope.
+	Scope func(*http.Request) string
+}
+
+// Idempotency returns middleware which makes retries of unsafe requests safe. The response to the first request with
+// an Idempotency-Key header is stored and replayed for later requests from the same client with the same key and the
+// same method, URL and body. A request reusing a key with a different payload is rejected with 422 Unprocessable
+// Entity and a request arriving while the first is still being handled with 409 Conflict. Responses with a 5xx, 408
+// Request Timeout or 429 Too Many Requests status and streamed responses are not stored, so the request may be retried.
+func Idempotency(opts IdempotencyOptions) func(http.Handler) http.Handler {
+	if opts.Store == nil {
+		opts.Store = NewMemoryIdempotencyStore()
+	}
+	if opts.TTL == 0 {
+		opts.TTL = 24 * time.Hour
+	}
+	if opts.LockTimeout == 0 {
+		opts.LockTimeout = time.Minute
+	}
+	if opts.Methods == nil {
+		opts.Methods = []string{"POST", "PATCH"}
+	}
+	if opts.Scope == nil {
+		opts.Scope = credentialScope
+	}
+	if opts.MaxBodySize == 0 {
+		opts.MaxBodySize = 1 << 20
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !slices.Contains(opts.Methods, r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			key := r.Header.Get(IdempotencyKeyHeader)
+			switch {
+			case key == "" && opts.Required:
+				WriteError(w, http.StatusBadRequest, "missing "+IdempotencyKeyHeader+" header")
+				return
+			case key == "":
+				next.ServeHTTP(w, r)
+				return
+			case len(key) > maxIdempotencyKey:
+				WriteError(w, http.StatusBadRequest, IdempotencyKeyHeader+" header is too long")
+				return
+			}
+			key = opts.Scope(r) + "\x00" + key
+
+			if r.ContentLength > opts.MaxBodySize {
+				WriteError(w, http.StatusRequestEntityTooLarge, ErrBodyTooLarge.Error())
+				return
+			}
+			body, err := io.ReadAll(io.LimitReader(r.Body, opts.MaxBodySize+1))
+			switch {
+			case int64(len(body)) > opts.MaxBodySize || errors.Is(err, ErrBodyTooLarge):
+				WriteError(w, http.StatusRequestEntityTooLarge, ErrBodyTooLarge.Error())
+				return
+			case err != nil:
+				WriteError(w, http.StatusBadRequest, "failed to read request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			fingerprint := requestFingerprint(r, body)
+
+			token := newReservationToken()
+			record, ok := opts.Store.Reserve(key, fingerprint, token, opts.LockTimeout)
+			if !ok {
+				switch {
+				case record.Fingerprint != fingerprint:
+					WriteError(w, http.StatusUnprocessableEntity,
+						IdempotencyKeyHeader+" was already used for a different request")
+				case record.Response == nil:
+					w.Header().Set("Retry-After", "1")
+					WriteError(w, http.StatusConflict, "a request with this "+IdempotencyKeyHeader+" is in progress")
+				default:
+					w.Header().Set(IdempotentReplayedHeader, "true")
+					writeStored(w, record.Response)
+				}
+				return
+			}
+
+			cw := &cacheWriter{ResponseWriter: w}
+			completed := false
+			defer func() {
+				if !completed {
+					opts.Store.Release(key, token)
+				}
+			}()
+			next.ServeHTTP(cw, r)
+			if cw.status == 0 {
+				cw.status = http.StatusOK
+			}
+			if !cw.streaming && storableOutcome(cw.status) {
+				opts.Store.Complete(key, token, &CachedResponse{cw.status, w.Header().Clone(), cw.body}, opts.TTL)
+				completed = true
+			}
+			if !cw.streaming {
+				writeStored(w, &CachedResponse{cw.status, nil, cw.body})
+			}
+		})
+	}
+}
+
+// newReservationToken returns a random token identifying a reservation of an idempotency key.
+func newReservationToken() string {
+	token := make([]byte, 16)
+	rand.Read(token)
+	return hex.EncodeToString(token)
+}
+
+// storableOutcome reports whether a response status is final, rather than asking the client to retry.
+func storableOutcome(status int) bool {
+	return status < 500 && status != http.StatusRequestTimeout && status != http.StatusTooManyRequests
+}
+
+// credentialScope identifies the client of a request by a hash of its Authorization and Cookie headers.
+func credentialScope(r *http.Request) string {
+	h := sha256.New()
+	io.WriteString(h, r.Header.Get("Authorization")+"\n"+strings.Join(r.Header.Values("Cookie"), "; "))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// requestFingerprint identifies a request by its method, URL and body.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeStored writes a stored response, copying its headers first.
+func writeStored(w http.ResponseWriter, resp *CachedResponse) {
+	h := w.Header()
+	for k, v := range resp.Header {
+		h[k] = append([]string(nil), v...)
+	}
+	w.WriteHeader(resp.Status)
+	w.Write(resp.Body)
+}
+
+// MemoryIdempotencyStore is an in-memory IdempotencyStore. Expired keys are removed as the store is used.
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*memoryIdempotencyRecord
+	swept   time.Time
+}
+
+type memoryIdempotencyRecord struct {
+	IdempotencyRecord
+	token   string
+	expires time.Time
+}
+
+// NewMemoryIdempotencyStore creates an empty MemoryIdempotencyStore.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: make(map[string]*memoryIdempotencyRecord), swept: time.Now()}
+}
+
+// Reserve claims a key unless it is already reserved or holds an unexpired response.
+func (s *MemoryIdempotencyStore) Reserve(key, fingerprint, token string, ttl time.Duration) (*IdempotencyRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.swept) >= time.Minute {
+		for k, rec := range s.records {
+			if now.After(rec.expires) {
+				delete(s.records, k)
+			}
+		}
+		s.swept = now
+	}
+
+	if rec, ok := s.records[key]; ok && !now.After(rec.expires) {
+		copied := rec.IdempotencyRecord
+		return &copied, false
+	}
+	s.records[key] = &memoryIdempotencyRecord{IdempotencyRecord{Fingerprint: fingerprint}, token, now.Add(ttl)}
+	return nil, true
+}
+
+// Complete stores the response for a key reserved with the token.
+func (s *MemoryIdempotencyStore) Complete(key, token string, resp *CachedResponse, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rec, ok := s.records[key]; ok && rec.token == token && rec.Response == nil {
+		rec.Response = resp
+		rec.expires = time.Now().Add(ttl)
+	}
+}
+
+// Release removes a key reserved with the token.
+func (s *MemoryIdempotencyStore) Release(key, token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rec, ok := s.records[key]; ok && rec.token == token {
+		delete(s.records, key)
+	}
+}
+
+// Len returns the number of keys held, including expired keys which have not been removed yet.
+func (s *MemoryIdempotencyStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.records)
+}
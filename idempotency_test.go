@@ -0,0 +1,199 @@
+package xrouter
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newIdempotentRouter(opts IdempotencyOptions, handler Route) Router {
+	r := New()
+	r.Use(Idempotency(opts))
+	r.POST("/orders", handler)
+	r.GET("/orders", handler)
+	return r
+}
+
+func callIdempotent(r Router, method, key, body string, header ...string) *httptest.ResponseRecorder {
+	if key != "" {
+		header = append([]string{IdempotencyKeyHeader, key}, header...)
+	}
+	return send(r, method, "/orders", strings.NewReader(body), header...)
+}
+
+func TestIdempotencyReplaysResponses(t *testing.T) {
+	var orders atomic.Int32
+	r := newIdempotentRouter(IdempotencyOptions{}, func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		id := strconv.Itoa(int(orders.Add(1)))
+		w.Header().Set("Location", "/orders/"+id)
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, id+":"+string(body))
+	})
+
+	w := callIdempotent(r, "POST", "k1", "book")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "1:book", w.Body.String())
+	assert.Empty(t, w.Header().Get(IdempotentReplayedHeader))
+
+	w = callIdempotent(r, "POST", "k1", "book")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "1:book", w.Body.String())
+	assert.Equal(t, "/orders/1", w.Header().Get("Location"))
+	assert.Equal(t, "true", w.Header().Get(IdempotentReplayedHeader))
+
+	w = callIdempotent(r, "POST", "k1", "pen")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	// Requests without a key and safe methods are not tracked.
+	assert.Equal(t, "2:book", callIdempotent(r, "POST", "", "book").Body.String())
+	assert.Equal(t, "3:", callIdempotent(r, "GET", "k1", "").Body.String())
+	assert.Equal(t, int32(3), orders.Load())
+}
+
+func TestIdempotencyConcurrentDuplicates(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	r := newIdempotentRouter(IdempotencyOptions{}, func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- callIdempotent(r, "POST", "k1", "a") }()
+	<-started
+
+	w := callIdempotent(r, "POST", "k1", "a")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	close(release)
+	assert.Equal(t, "done", (<-done).Body.String())
+	assert.Equal(t, "done", callIdempotent(r, "POST", "k1", "a").Body.String())
+}
+
+func TestIdempotencyRetriesServerErrors(t *testing.T) {
+	for _, status := range []int{http.StatusServiceUnavailable, http.StatusRequestTimeout, http.StatusTooManyRequests} {
+		var calls atomic.Int32
+		r := newIdempotentRouter(IdempotencyOptions{}, func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				WriteError(w, status, "try again")
+				return
+			}
+			io.WriteString(w, "ok")
+		})
+
+		assert.Equal(t, status, callIdempotent(r, "POST", "k1", "a").Code)
+		assert.Equal(t, "ok", callIdempotent(r, "POST", "k1", "a").Body.String())
+		assert.Equal(t, "ok", callIdempotent(r, "POST", "k1", "a").Body.String())
+		assert.Equal(t, int32(2), calls.Load(), status)
+	}
+}
+
+func TestIdempotencyScopesByCredentials(t *testing.T) {
+	var calls atomic.Int32
+	r := newIdempotentRouter(IdempotencyOptions{}, func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strconv.Itoa(int(calls.Add(1))))
+	})
+
+	assert.Equal(t, "1", callIdempotent(r, "POST", "k1", "a", "Authorization", "Bearer alice").Body.String())
+	assert.Equal(t, "2", callIdempotent(r, "POST", "k1", "a", "Authorization", "Bearer bob").Body.String())
+	assert.Equal(t, "3", callIdempotent(r, "POST", "k1", "a", "Cookie", "session=carol").Body.String())
+	assert.Equal(t, "4", callIdempotent(r, "POST", "k1", "a").Body.String())
+	assert.Equal(t, "1", callIdempotent(r, "POST", "k1", "a", "Authorization", "Bearer alice").Body.String())
+}
+
+func TestIdempotencyLimitsBodies(t *testing.T) {
+	r := newIdempotentRouter(IdempotencyOptions{MaxBodySize: 4}, GetTest)
+	assert.Equal(t, http.StatusOK, callIdempotent(r, "POST", "k1", "abcd").Code)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, callIdempotent(r, "POST", "k2", "abcde").Code)
+
+	// Bodies of unknown length are cut off at the limit.
+	body := io.MultiReader(strings.NewReader("abc"), strings.NewReader("de"))
+	w := send(r, "POST", "/orders", body, IdempotencyKeyHeader, "k3")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	// Requests without a key are left to the handler.
+	assert.Equal(t, http.StatusOK, callIdempotent(r, "POST", "", "abcde").Code)
+}
+
+func TestIdempotencyOptions(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	var calls atomic.Int32
+	r := newIdempotentRouter(IdempotencyOptions{
+		Store:    store,
+		Required: true,
+		Scope:    func(r *http.Request) string { return r.Header.Get("X-User") },
+	}, func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strconv.Itoa(int(calls.Add(1))))
+	})
+
+	assert.Equal(t, http.StatusBadRequest, callIdempotent(r, "POST", "", "a").Code)
+	assert.Equal(t, http.StatusBadRequest, callIdempotent(r, "POST", strings.Repeat("k", 256), "a").Code)
+
+	assert.Equal(t, "1", callIdempotent(r, "POST", "k1", "a", "X-User", "alice").Body.String())
+	assert.Equal(t, "2", callIdempotent(r, "POST", "k1", "a", "X-User", "bob").Body.String())
+	assert.Equal(t, "1", callIdempotent(r, "POST", "k1", "a", "X-User", "alice").Body.String())
+	assert.Equal(t, 2, store.Len())
+}
+
+func TestMemoryIdempotencyStoreExpires(t *testing.T) {
+	s := NewMemoryIdempotencyStore()
+	_, ok := s.Reserve("k", "a", "t1", time.Millisecond)
+	assert.True(t, ok)
+	rec, ok := s.Reserve("k", "b", "t2", time.Millisecond)
+	assert.False(t, ok)
+	assert.Equal(t, &IdempotencyRecord{Fingerprint: "a"}, rec)
+
+	time.Sleep(5 * time.Millisecond)
+	_, ok = s.Reserve("k", "b", "t3", time.Hour)
+	assert.True(t, ok)
+
+	// The request whose reservation expired can no longer complete or release the key.
+	s.Complete("k", "t1", &CachedResponse{Status: http.StatusCreated}, time.Hour)
+	s.Release("k", "t1")
+	rec, _ = s.Reserve("k", "b", "t4", time.Hour)
+	assert.Equal(t, &IdempotencyRecord{Fingerprint: "b"}, rec)
+
+	s.Complete("k", "t3", &CachedResponse{Status: http.StatusOK}, time.Hour)
+	rec, _ = s.Reserve("k", "b", "t4", time.Hour)
+	assert.Equal(t, http.StatusOK, rec.Response.Status)
+
+	s.Release("k", "t3")
+	assert.Equal(t, 0, s.Len())
+}
+
+func TestIdempotencyStaleRequest(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	var calls atomic.Int32
+	r := newIdempotentRouter(IdempotencyOptions{LockTimeout: time.Millisecond},
+		func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			n := calls.Add(1)
+			if n == 1 {
+				started <- struct{}{}
+				<-release
+			}
+			io.WriteString(w, strconv.Itoa(int(n)))
+		})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- callIdempotent(r, "POST", "k1", "a") }()
+	<-started
+	time.Sleep(5 * time.Millisecond)
+
+	// The lock expired, so a retry is handled and its response is the one replayed.
+	assert.Equal(t, "2", callIdempotent(r, "POST", "k1", "a").Body.String())
+	close(release)
+	assert.Equal(t, "1", (<-done).Body.String())
+	assert.Equal(t, "2", callIdempotent(r, "POST", "k1", "a").Body.String())
+}
@@ -0,0 +1,157 @@
+package xrouter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// HTTPError is an error which carries the HTTP status code that should be returned to the client.
+type HTTPError struct {
+	Status  int
+	Message string
+}
+
+// NewHTTPError creates an HTTPError. If the message is empty the status text is used.
+func NewHTTPError(status int, message string) *HTTPError {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return &HTTPError{status, message}
+}
+
+func (e *HTTPError) Error() string {
+	return e.Message
+}
+
+// Validator is implemented by request types which can validate themselves after decoding.
+type Validator interface {
+	Validate() error
+}
+
+// errorBody is the JSON body written for all errors.
+type errorBody struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// WriteJSON encodes v as JSON and writes it with the given status code.
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
+
+// WriteError writes a JSON error body with the given status code.
+func WriteError(w http.ResponseWriter, status int, message string) {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	WriteJSON(w, status, errorBody{status, message})
+}
+
+// JSON adds a handler which decodes the request body into Req, validates it, calls fn and encodes the returned Resp.
+//
+// Malformed bodies are answered with 400, non-JSON content types with 415 and validation failures with 422.
+// Errors returned from fn are answered with their status if they are an *HTTPError and with 500 otherwise.
+// Successful responses use 201 for POST, 204 when Resp is an empty struct and 200 for everything else.
+// The request and response types are recorded in the route table.
+func JSON[Req, Resp any](group RouterGroup, method, path string, fn func(context.Context, Req) (Resp, error), opts ...RouteOption) {
+	respType := reflect.TypeFor[Resp]()
+	status := successStatus(method, respType)
+
+	route := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		var req Req
+		if err := decodeJSON(r, &req); err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		if err := validate(&req); err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+
+		resp, err := fn(ctx, req)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+
+		if status == http.StatusNoContent {
+			w.WriteHeader(status)
+			return
+		}
+		WriteJSON(w, status, resp)
+	}
+
+	opts = append([]RouteOption{Schema(reflect.TypeFor[Req](), respType)}, opts...)
+	group.Handle(method, path, route, opts...)
+}
+
+// successStatus returns the status code used by JSON handlers for a successful response.
+func successStatus(method string, resp reflect.Type) int {
+	switch {
+	case isEmptyType(resp):
+		return http.StatusNoContent
+	case method == "POST":
+		return http.StatusCreated
+	default:
+		return http.StatusOK
+	}
+}
+
+// isEmptyType returns true for types which carry no body, such as struct{}.
+func isEmptyType(t reflect.Type) bool {
+	return t == nil || (t.Kind() == reflect.Struct && t.NumField() == 0)
+}
+
+// decodeJSON decodes the request body into v. An empty body leaves v untouched.
+func decodeJSON(r *http.Request, v interface{}) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mt, _, err := mime.ParseMediaType(ct)
+		if err != nil || (mt != "application/json" && !strings.HasSuffix(mt, "+json")) {
+			return NewHTTPError(http.StatusUnsupportedMediaType, "content type must be application/json")
+		}
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && err != io.EOF {
+		return NewHTTPError(http.StatusBadRequest, "invalid request body: "+err.Error())
+	}
+	return nil
+}
+
+// validate calls Validate on v, or the value it points to, if it implements Validator.
+func validate(v interface{}) error {
+	val, ok := v.(Validator)
+	if elem := reflect.ValueOf(v).Elem(); !ok && !(elem.Kind() == reflect.Ptr && elem.IsNil()) {
+		val, ok = elem.Interface().(Validator)
+	}
+	if !ok {
+		return nil
+	}
+	if err := val.Validate(); err != nil {
+		var herr *HTTPError
+		if errors.As(err, &herr) {
+			return herr
+		}
+		return NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	}
+	return nil
+}
+
+// writeHTTPError writes err as a JSON error body. Errors which are not an *HTTPError are reported as 500 without exposing the message.
+func writeHTTPError(w http.ResponseWriter, err error) {
+	var herr *HTTPError
+	if errors.As(err, &herr) {
+		WriteError(w, herr.Status, herr.Message)
+		return
+	}
+	WriteError(w, http.StatusInternalServerError, "")
+}
@@ -0,0 +1,98 @@
+package xrouter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type createUser struct {
+	Name string `json:"name"`
+}
+
+func (c createUser) Validate() error {
+	if c.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+type user struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func newJSONRouter() Router {
+	r := New()
+	api := r.Group("/api")
+	JSON(api, "POST", "/users", func(ctx context.Context, req createUser) (user, error) {
+		if req.Name == "taken" {
+			return user{}, NewHTTPError(http.StatusConflict, "name already taken")
+		}
+		if req.Name == "boom" {
+			return user{}, errors.New("database unavailable")
+		}
+		return user{"1", req.Name}, nil
+	})
+	JSON(api, "GET", "/users/:id", func(ctx context.Context, _ struct{}) (user, error) {
+		return user{Param(ctx, "id"), "alice"}, nil
+	})
+	JSON(api, "DELETE", "/users/:id", func(ctx context.Context, _ struct{}) (struct{}, error) {
+		return struct{}{}, nil
+	})
+	return r
+}
+
+func TestJSONHandler(t *testing.T) {
+	r := newJSONRouter()
+
+	w := send(r, "POST", "/api/users", strings.NewReader(`{"name":"bob"}`), "Content-Type", "application/json")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"id":"1","name":"bob"}`, w.Body.String())
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+
+	w = send(r, "GET", "/api/users/42", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"id":"42","name":"alice"}`, w.Body.String())
+
+	w = send(r, "DELETE", "/api/users/42", nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, 0, w.Body.Len())
+}
+
+func TestJSONHandlerErrors(t *testing.T) {
+	r := newJSONRouter()
+
+	w := send(r, "POST", "/api/users", strings.NewReader(`{"name":`), "Content-Type", "application/json")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = send(r, "POST", "/api/users", strings.NewReader(`{"name":"bob"}`), "Content-Type", "text/plain")
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+	w = send(r, "POST", "/api/users", strings.NewReader(`{}`), "Content-Type", "application/json")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"status":422,"message":"name is required"}`, w.Body.String())
+
+	w = send(r, "POST", "/api/users", strings.NewReader(`{"name":"taken"}`), "Content-Type", "application/json")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.JSONEq(t, `{"status":409,"message":"name already taken"}`, w.Body.String())
+
+	w = send(r, "POST", "/api/users", strings.NewReader(`{"name":"boom"}`), "Content-Type", "application/json")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"status":500,"message":"Internal Server Error"}`, w.Body.String())
+}
+
+func TestJSONRouteSchema(t *testing.T) {
+	routes := newJSONRouter().Routes()
+	assert.Equal(t, 3, len(routes))
+
+	assert.Equal(t, "POST", routes[0].Method)
+	assert.Equal(t, "/api/users", routes[0].Path)
+	assert.Equal(t, reflect.TypeOf(createUser{}), routes[0].Request)
+	assert.Equal(t, reflect.TypeOf(user{}), routes[0].Response)
+}
@@ -0,0 +1,238 @@
+package xrouter
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Priority orders routes for load shedding. Lower priorities are rejected first when the server is saturated.
+type Priority int
+
+// Route priorities. Routes are PriorityNormal unless they set another priority with RoutePriority.
+const (
+	PriorityBackground Priority = iota - 2
+	PriorityLow
+	PriorityNormal
+	PriorityHigh
+	PriorityCritical
+)
+
+// String returns the name of the priority.
+func (p Priority) String() string {
+	switch {
+	case p <= PriorityBackground:
+		return "background"
+	case p == PriorityLow:
+		return "low"
+	case p == PriorityNormal:
+		return "normal"
+	case p == PriorityHigh:
+		return "high"
+	}
+	return "critical"
+}
+
+// MarshalText encodes the priority as its name.
+func (p Priority) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// share returns the part of the concurrency limit available to requests of the priority. Critical requests are never
+// shed.
+func (p Priority) share() float64 {
+	switch {
+	case p <= PriorityBackground:
+		return 0.5
+	case p == PriorityLow:
+		return 0.75
+	case p == PriorityNormal:
+		return 0.9
+	case p == PriorityHigh:
+		return 1
+	}
+	return math.Inf(1)
+}
+
+// RoutePriority sets the load shedding priority of a route. Passed to RouterGroup.Defaults it sets the priority of
+// every route of the group.
+func RoutePriority(p Priority) RouteOption {
+	return func(info *RouteInfo) {
+		info.Priority = p
+	}
+}
+
+// LoadShedderOptions configures a LoadShedder.
+type LoadShedderOptions struct {
+	// InitialLimit is the number of concurrent requests allowed before the limit has adapted. Defaults to 20.
+	InitialLimit int
+
+	// MinLimit and MaxLimit bound the adaptive limit. They default to 4 and 1000.
+	MinLimit int
+	MaxLimit int
+
+	// Tolerance is how many times slower than the baseline requests may become before the limit is lowered.
+	// Defaults to 2.
+	Tolerance float64
+
+	// Interval is how often the limit is adjusted. Defaults to one second.
+	Interval time.Duration
+
+	// RetryAfter is sent in the Retry-After header of rejected requests. Defaults to one second.
+	RetryAfter time.Duration
+
+	// Events receives a LoadShedEvent for each rejected request.
+	Events func(Event)
+}
+
+// baselineIntervals is the number of intervals after which the baseline latency is measured again.
+const baselineIntervals = 30
+
+// LoadShedder rejects requests with 503 Service Unavailable when too many are in flight, starting with the lowest
+// priority. The concurrency limit adapts to latency: it shrinks when requests take longer than the baseline allows
+// and grows while they do not and the limit is being used.
+type LoadShedder struct {
+	name string
+	opts LoadShedderOptions
+
+	mu        sync.Mutex
+	limit     float64
+	inFlight  int
+	peak      int
+	baseline  time.Duration
+	windowMin time.Duration
+	total     time.Duration
+	count     int
+	start     time.Time
+	windows   int
+	shed      map[Priority]uint64
+}
+
+// LoadShedderStats reports the state of a LoadShedder.
+type LoadShedderStats struct {
+	Limit    int                 `json:"limit"`
+	InFlight int                 `json:"in_flight"`
+	Baseline string              `json:"baseline"`
+	Shed     map[Priority]uint64 `json:"shed"`
+}
+
+// NewLoadShedder creates a load shedder. The name identifies it in events and introspection.
+func NewLoadShedder(name string, opts LoadShedderOptions) *LoadShedder {
+	if opts.InitialLimit <= 0 {
+		opts.InitialLimit = 20
+	}
+	if opts.MinLimit <= 0 {
+		opts.MinLimit = 4
+	}
+	if opts.MaxLimit <= 0 {
+		opts.MaxLimit = 1000
+	}
+	if opts.Tolerance == 0 {
+		opts.Tolerance = 2
+	}
+	if opts.Interval == 0 {
+		opts.Interval = time.Second
+	}
+	if opts.RetryAfter == 0 {
+		opts.RetryAfter = time.Second
+	}
+	return &LoadShedder{name: name, opts: opts, limit: float64(opts.InitialLimit), start: time.Now(),
+		shed: make(map[Priority]uint64)}
+}
+
+// Name returns the name of the load shedder.
+func (s *LoadShedder) Name() string {
+	return s.name
+}
+
+// Middleware sheds requests to the wrapped handler according to the priority of their route.
+func (s *LoadShedder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var priority Priority
+		if info, ok := RouteFromContext(r.Context()); ok {
+			priority = info.Priority
+		}
+		if !s.acquire(priority) {
+			if s.opts.Events != nil {
+				s.opts.Events(LoadShedEvent{Name: s.name, Route: partitionKey(r, true), Priority: priority})
+			}
+			w.Header().Set("Retry-After", retryAfter(s.opts.RetryAfter))
+			WriteError(w, http.StatusServiceUnavailable, "server overloaded")
+			return
+		}
+
+		start := time.Now()
+		defer func() {
+			now := time.Now()
+			s.release(now.Sub(start), now)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Stats returns the current limit, the requests in flight and the number of requests shed by priority.
+func (s *LoadShedder) Stats() LoadShedderStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	shed := make(map[Priority]uint64, len(s.shed))
+	for p, n := range s.shed {
+		shed[p] = n
+	}
+	return LoadShedderStats{int(s.limit), s.inFlight, s.baseline.String(), shed}
+}
+
+// Introspect returns the result of Stats.
+func (s *LoadShedder) Introspect() interface{} {
+	return s.Stats()
+}
+
+// acquire admits a request if the requests in flight leave room for its priority, counting it as shed otherwise.
+func (s *LoadShedder) acquire(p Priority) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if float64(s.inFlight) >= s.limit*p.share() {
+		s.shed[p]++
+		return false
+	}
+	s.inFlight++
+	s.peak = max(s.peak, s.inFlight)
+	return true
+}
+
+// release records the latency of a finished request and adjusts the limit at the end of each interval.
+func (s *LoadShedder) release(latency time.Duration, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inFlight--
+	s.total += latency
+	s.count++
+	if s.windowMin == 0 || latency < s.windowMin {
+		s.windowMin = latency
+	}
+	if s.baseline == 0 || latency < s.baseline {
+		s.baseline = latency
+	}
+	if now.Sub(s.start) >= s.opts.Interval {
+		s.adapt(now)
+	}
+}
+
+// adapt shrinks the limit in proportion to how far the average latency of the interval exceeds the tolerated
+// latency, or grows it when latency is fine and more than half of the limit was used. The caller must hold the lock.
+func (s *LoadShedder) adapt(now time.Time) {
+	average := s.total / time.Duration(s.count)
+	gradient := math.Min(1, s.opts.Tolerance*float64(s.baseline)/float64(average))
+	if gradient < 1 {
+		s.limit *= math.Max(0.5, gradient)
+	} else if float64(s.peak)*2 >= s.limit {
+		s.limit += math.Sqrt(s.limit)
+	}
+	s.limit = math.Max(float64(s.opts.MinLimit), math.Min(float64(s.opts.MaxLimit), s.limit))
+
+	// Measure the baseline again from time to time so that it follows lasting changes in the workload.
+	if s.windows++; s.windows%baselineIntervals == 0 {
+		s.baseline = s.windowMin
+	}
+	s.start, s.total, s.count, s.windowMin, s.peak = now, 0, 0, 0, s.inFlight
+}
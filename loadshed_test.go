@@ -0,0 +1,112 @@
+package xrouter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadShedderPriorities(t *testing.T) {
+	events := &eventLog{}
+	s := NewLoadShedder("api", LoadShedderOptions{InitialLimit: 4, MinLimit: 4, MaxLimit: 4, Interval: time.Hour,
+		RetryAfter: 2 * time.Second, Events: events.add})
+	release := make(chan struct{})
+	block := func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		<-release
+	}
+
+	r := New()
+	r.Use(s.Middleware)
+	r.GET("/api/block", block)
+	r.GET("/api/test", GetTest)
+	r.GET("/healthz", GetTest, RoutePriority(PriorityCritical))
+	batch := r.Group("/batch")
+	batch.Defaults(RoutePriority(PriorityBackground))
+	batch.GET("/export", GetTest)
+
+	call := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.Handler().ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+	done := make(chan int, 4)
+	hold := func(n int) {
+		go func() { done <- call("/api/block").Code }()
+		for deadline := time.Now().Add(time.Second); s.Stats().InFlight < n; time.Sleep(time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("%d requests in flight, expected %d", s.Stats().InFlight, n)
+			}
+		}
+	}
+
+	// Background requests may only use half of the limit.
+	assert.Equal(t, http.StatusOK, call("/batch/export").Code)
+	hold(1)
+	hold(2)
+	w := call("/batch/export")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+
+	// Normal requests may use 90% of the limit and critical requests are never shed.
+	assert.Equal(t, http.StatusOK, call("/api/test").Code)
+	hold(3)
+	hold(4)
+	assert.Equal(t, http.StatusServiceUnavailable, call("/api/test").Code)
+	assert.Equal(t, http.StatusOK, call("/healthz").Code)
+
+	close(release)
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, http.StatusOK, <-done)
+	}
+
+	stats := s.Stats()
+	assert.Equal(t, 4, stats.Limit)
+	assert.Equal(t, 0, stats.InFlight)
+	assert.Equal(t, map[Priority]uint64{PriorityBackground: 1, PriorityNormal: 1}, stats.Shed)
+	assert.Equal(t, []Event{
+		LoadShedEvent{Name: "api", Route: "GET /batch/export", Priority: PriorityBackground},
+		LoadShedEvent{Name: "api", Route: "GET /api/test", Priority: PriorityNormal},
+	}, events.list())
+
+	info, _, _ := r.Lookup("GET", "/batch/export")
+	assert.Equal(t, PriorityBackground, info.Priority)
+
+	body, _ := json.Marshal(s.Introspect())
+	assert.Contains(t, string(body), `"shed":{"background":1,"normal":1}`)
+}
+
+func TestLoadShedderAdaptsLimit(t *testing.T) {
+	s := NewLoadShedder("api", LoadShedderOptions{InitialLimit: 20, Interval: time.Second})
+	start := s.start
+
+	// Latency within the tolerance grows the limit while more than half of it is used.
+	for i := 0; i < 15; i++ {
+		assert.True(t, s.acquire(PriorityNormal))
+	}
+	for i := 0; i < 14; i++ {
+		s.release(10*time.Millisecond, start.Add(time.Second/2))
+	}
+	s.release(10*time.Millisecond, start.Add(time.Second))
+	assert.Equal(t, 24, s.Stats().Limit)
+	assert.Equal(t, "10ms", s.Stats().Baseline)
+
+	// Latency four times the baseline halves the limit.
+	s.acquire(PriorityNormal)
+	s.release(40*time.Millisecond, start.Add(2*time.Second))
+	assert.Equal(t, 12, s.Stats().Limit)
+
+	// The limit does not grow while it is mostly unused, and never falls below the minimum.
+	s.acquire(PriorityNormal)
+	s.release(10*time.Millisecond, start.Add(3*time.Second))
+	assert.Equal(t, 12, s.Stats().Limit)
+	for i := 4; i < 10; i++ {
+		s.acquire(PriorityNormal)
+		s.release(time.Second, start.Add(time.Duration(i)*time.Second))
+	}
+	assert.Equal(t, 4, s.Stats().Limit)
+}
@@ -16,6 +16,18 @@ import (
 // ParamsKey is the key for contexts which grant access to the url params.
 const ParamsKey = "params"
 
+type contextKey int
+
+const (
+	routeKey contextKey = iota
+	versionKey
+	variantKey
+	csrfKey
+	deprecationKey
+	observerKey
+	shutdownKey
+)
+
 // Param returns a URL parameter by name
 func Param(ctx context.Context, key string) string {
 	if params, ok := ctx.Value(ParamsKey).(httprouter.Params); ok {
@@ -24,11 +36,36 @@ func Param(ctx context.Context, key string) string {
 	return ""
 }
 
+// RouteFromContext returns the route which is handling the request.
+func RouteFromContext(ctx context.Context) (*RouteInfo, bool) {
+	info, ok := ctx.Value(routeKey).(*RouteInfo)
+	return info, ok
+}
+
+// ObserveRoute returns a context which calls f with the route, and its URL parameters, which handles a request using
+// the context. The route is reported after path normalization and constraint checks, so tests and tracing see the route
+// which actually served the request. Requests which no route handles are not reported.
+func ObserveRoute(ctx context.Context, f func(RouteInfo, httprouter.Params)) context.Context {
+	return context.WithValue(ctx, observerKey, f)
+}
+
+// MetaFromContext returns a metadata annotation of the route which is handling the request.
+func MetaFromContext(ctx context.Context, key string) string {
+	if info, ok := RouteFromContext(ctx); ok {
+		return info.Metadata[key]
+	}
+	return ""
+}
+
 // httpParamsHandler is middleware which links the middleware and httprouter.
-func httpParamsHandler(chain alice.Chain, handler Route) httprouter.Handle {
-	h := wrapper(chain, handler)
+func httpParamsHandler(info *RouteInfo, h http.Handler) httprouter.Handle {
 	return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
-		req = req.WithContext(context.WithValue(req.Context(), ParamsKey, params))
+		ctx := req.Context()
+		if observe, ok := ctx.Value(observerKey).(func(RouteInfo, httprouter.Params)); ok {
+			observe(*info, params)
+		}
+		ctx = context.WithValue(ctx, ParamsKey, params)
+		req = req.WithContext(context.WithValue(ctx, routeKey, info))
 		h.ServeHTTP(w, req)
 	}
 }
@@ -38,17 +75,25 @@ func HTTPHandler(c alice.Chain, fs http.Handler) http.Handler {
 	return c.Then(fs)
 }
 
-// LogHandler instantiates a new xlog HTTP handler using the given log.
+// LogHandler instantiates a new xlog HTTP handler using the given log. Requests to silent routes are not logged.
 func LogHandler() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if info, ok := RouteFromContext(r.Context()); ok && info.Silent {
+				next.ServeHTTP(w, r)
+				return
+			}
 			ptw := passThroughResponseWriter{200, w}
 			start := time.Now()
 			next.ServeHTTP(&ptw, r)
-			xlog.FromContext(r.Context()).Info(http.StatusText(ptw.StatusCode), xlog.F{
+			fields := xlog.F{
 				"duration": time.Now().Sub(start).String(),
 				"status":   ptw.StatusCode,
-			})
+			}
+			if cache := w.Header().Get(CacheHeader); cache != "" {
+				fields["cache"] = cache
+			}
+			xlog.FromContext(r.Context()).Info(http.StatusText(ptw.StatusCode), fields)
 		})
 	}
 }
@@ -74,3 +119,15 @@ func (p *passThroughResponseWriter) Write(data []byte) (int, error) {
 func (p *passThroughResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	return p.ResponseWriter.(http.Hijacker).Hijack()
 }
+
+// Flush flushes the underlying writer so that streaming responses work through the log handler.
+func (p *passThroughResponseWriter) Flush() {
+	if f, ok := p.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter for use with http.ResponseController.
+func (p *passThroughResponseWriter) Unwrap() http.ResponseWriter {
+	return p.ResponseWriter
+}
@@ -1,22 +1,209 @@
 package mocks
 
-import "github.com/eliquious/xrouter"
-import "github.com/stretchr/testify/mock"
+import (
+	"context"
+	"net/http"
+	"net/url"
 
-import "net/http"
+	"github.com/eliquious/xrouter"
+	"github.com/julienschmidt/httprouter"
+	"github.com/justinas/alice"
+	"github.com/stretchr/testify/mock"
+)
 
+// Router is a mock type for the xrouter.Router interface.
 type Router struct {
 	mock.Mock
 }
 
-// StaticRoot provides a mock function with given fields: fs
-func (_m *Router) StaticRoot(fs http.Handler) {
-	_m.Called(fs)
+var _ xrouter.Router = (*Router)(nil)
+
+// Use provides a mock function with given fields: f
+func (_m *Router) Use(f func(http.Handler) http.Handler) {
+	_m.Called(f)
 }
 
-// StaticFiles provides a mock function with given fields: path, fs
-func (_m *Router) StaticFiles(path string, fs http.Handler) {
-	_m.Called(path, fs)
+// UseNamed provides a mock function with given fields: name, f
+func (_m *Router) UseNamed(name string, f func(http.Handler) http.Handler) {
+	_m.Called(name, f)
+}
+
+// UseBefore provides a mock function with given fields: before, name, f
+func (_m *Router) UseBefore(before string, name string, f func(http.Handler) http.Handler) {
+	_m.Called(before, name, f)
+}
+
+// UseAfter provides a mock function with given fields: after, name, f
+func (_m *Router) UseAfter(after string, name string, f func(http.Handler) http.Handler) {
+	_m.Called(after, name, f)
+}
+
+// Without provides a mock function with given fields: names
+func (_m *Router) Without(names ...string) {
+	_va := make([]interface{}, len(names))
+	for _i := range names {
+		_va[_i] = names[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, _va...)
+	_m.Called(_ca...)
+}
+
+// Chain provides a mock function with given fields:
+func (_m *Router) Chain() alice.Chain {
+	ret := _m.Called()
+
+	var r0 alice.Chain
+	if rf, ok := ret.Get(0).(func() alice.Chain); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(alice.Chain)
+	}
+
+	return r0
+}
+
+// Group provides a mock function with given fields: path
+func (_m *Router) Group(path string) xrouter.RouterGroup {
+	ret := _m.Called(path)
+
+	var r0 xrouter.RouterGroup
+	if rf, ok := ret.Get(0).(func(string) xrouter.RouterGroup); ok {
+		r0 = rf(path)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(xrouter.RouterGroup)
+		}
+	}
+
+	return r0
+}
+
+// Path provides a mock function with given fields:
+func (_m *Router) Path() string {
+	ret := _m.Called()
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func() string); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	return r0
+}
+
+// Handle provides a mock function with given fields: method, path, handler, opts
+func (_m *Router) Handle(method string, path string, handler xrouter.Route, opts ...xrouter.RouteOption) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, method, path, handler)
+	_ca = append(_ca, _va...)
+	_m.Called(_ca...)
+}
+
+// GET provides a mock function with given fields: path, handler, opts
+func (_m *Router) GET(path string, handler xrouter.Route, opts ...xrouter.RouteOption) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, path, handler)
+	_ca = append(_ca, _va...)
+	_m.Called(_ca...)
+}
+
+// POST provides a mock function with given fields: path, handler, opts
+func (_m *Router) POST(path string, handler xrouter.Route, opts ...xrouter.RouteOption) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, path, handler)
+	_ca = append(_ca, _va...)
+	_m.Called(_ca...)
+}
+
+// PUT provides a mock function with given fields: path, handler, opts
+func (_m *Router) PUT(path string, handler xrouter.Route, opts ...xrouter.RouteOption) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, path, handler)
+	_ca = append(_ca, _va...)
+	_m.Called(_ca...)
+}
+
+// OPTIONS provides a mock function with given fields: path, handler, opts
+func (_m *Router) OPTIONS(path string, handler xrouter.Route, opts ...xrouter.RouteOption) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, path, handler)
+	_ca = append(_ca, _va...)
+	_m.Called(_ca...)
+}
+
+// HEAD provides a mock function with given fields: path, handler, opts
+func (_m *Router) HEAD(path string, handler xrouter.Route, opts ...xrouter.RouteOption) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, path, handler)
+	_ca = append(_ca, _va...)
+	_m.Called(_ca...)
+}
+
+// PATCH provides a mock function with given fields: path, handler, opts
+func (_m *Router) PATCH(path string, handler xrouter.Route, opts ...xrouter.RouteOption) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, path, handler)
+	_ca = append(_ca, _va...)
+	_m.Called(_ca...)
+}
+
+// DELETE provides a mock function with given fields: path, handler, opts
+func (_m *Router) DELETE(path string, handler xrouter.Route, opts ...xrouter.RouteOption) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, path, handler)
+	_ca = append(_ca, _va...)
+	_m.Called(_ca...)
+}
+
+// WebSocket provides a mock function with given fields: path, handler, opts
+func (_m *Router) WebSocket(path string, handler xrouter.WebSocketHandler, opts ...xrouter.RouteOption) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, path, handler)
+	_ca = append(_ca, _va...)
+	_m.Called(_ca...)
+}
+
+// Proxy provides a mock function with given fields: path, target, opts
+func (_m *Router) Proxy(path string, target *url.URL, opts xrouter.ProxyOptions) {
+	_m.Called(path, target, opts)
 }
 
 // NotFound provides a mock function with given fields: _a0
@@ -29,6 +216,41 @@ func (_m *Router) MethodNotAllowed(_a0 http.Handler) {
 	_m.Called(_a0)
 }
 
+// Defaults provides a mock function with given fields: opts
+func (_m *Router) Defaults(opts ...xrouter.RouteOption) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, _va...)
+	_m.Called(_ca...)
+}
+
+// ShuttingDown provides a mock function with given fields:
+func (_m *Router) ShuttingDown() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// StaticRoot provides a mock function with given fields: fs
+func (_m *Router) StaticRoot(fs http.Handler) {
+	_m.Called(fs)
+}
+
+// StaticFiles provides a mock function with given fields: path, fs
+func (_m *Router) StaticFiles(path string, fs http.Handler) {
+	_m.Called(path, fs)
+}
+
 // Handler provides a mock function with given fields:
 func (_m *Router) Handler() http.Handler {
 	ret := _m.Called()
@@ -37,7 +259,9 @@ func (_m *Router) Handler() http.Handler {
 	if rf, ok := ret.Get(0).(func() http.Handler); ok {
 		r0 = rf()
 	} else {
-		r0 = ret.Get(0).(http.Handler)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(http.Handler)
+		}
 	}
 
 	return r0
@@ -47,3 +271,108 @@ func (_m *Router) Handler() http.Handler {
 func (_m *Router) EventHandler(_a0 func(xrouter.Event)) {
 	_m.Called(_a0)
 }
+
+// Routes provides a mock function with given fields:
+func (_m *Router) Routes() []xrouter.RouteInfo {
+	ret := _m.Called()
+
+	var r0 []xrouter.RouteInfo
+	if rf, ok := ret.Get(0).(func() []xrouter.RouteInfo); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]xrouter.RouteInfo)
+		}
+	}
+
+	return r0
+}
+
+// ServeOpenAPI provides a mock function with given fields: path, opts
+func (_m *Router) ServeOpenAPI(path string, opts xrouter.OpenAPIOptions) {
+	_m.Called(path, opts)
+}
+
+// Lookup provides a mock function with given fields: method, path
+func (_m *Router) Lookup(method string, path string) (xrouter.RouteInfo, httprouter.Params, bool) {
+	ret := _m.Called(method, path)
+
+	var r0 xrouter.RouteInfo
+	if rf, ok := ret.Get(0).(func(string, string) xrouter.RouteInfo); ok {
+		r0 = rf(method, path)
+	} else {
+		r0 = ret.Get(0).(xrouter.RouteInfo)
+	}
+
+	var r1 httprouter.Params
+	if rf, ok := ret.Get(1).(func(string, string) httprouter.Params); ok {
+		r1 = rf(method, path)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(httprouter.Params)
+		}
+	}
+
+	var r2 bool
+	if rf, ok := ret.Get(2).(func(string, string) bool); ok {
+		r2 = rf(method, path)
+	} else {
+		r2 = ret.Get(2).(bool)
+	}
+
+	return r0, r1, r2
+}
+
+// Validate provides a mock function with given fields:
+func (_m *Router) Validate() error {
+	ret := _m.Called()
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Serve provides a mock function with given fields: ctx, opts
+func (_m *Router) Serve(ctx context.Context, opts xrouter.ServerOptions) error {
+	ret := _m.Called(ctx, opts)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, xrouter.ServerOptions) error); ok {
+		r0 = rf(ctx, opts)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// OnStart provides a mock function with given fields: h
+func (_m *Router) OnStart(h xrouter.Hook) {
+	_m.Called(h)
+}
+
+// OnShutdown provides a mock function with given fields: h
+func (_m *Router) OnShutdown(h xrouter.Hook) {
+	_m.Called(h)
+}
+
+// DeprecatedCalls provides a mock function with given fields:
+func (_m *Router) DeprecatedCalls() []xrouter.DeprecatedCalls {
+	ret := _m.Called()
+
+	var r0 []xrouter.DeprecatedCalls
+	if rf, ok := ret.Get(0).(func() []xrouter.DeprecatedCalls); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]xrouter.DeprecatedCalls)
+		}
+	}
+
+	return r0
+}
@@ -1,21 +1,52 @@
 package mocks
 
-import "github.com/eliquious/xrouter"
-import "github.com/stretchr/testify/mock"
+import (
+	"net/http"
+	"net/url"
 
-import "net/http"
-
-import "github.com/justinas/alice"
+	"github.com/eliquious/xrouter"
+	"github.com/justinas/alice"
+	"github.com/stretchr/testify/mock"
+)
 
+// RouterGroup is a mock type for the xrouter.RouterGroup interface.
 type RouterGroup struct {
 	mock.Mock
 }
 
+var _ xrouter.RouterGroup = (*RouterGroup)(nil)
+
 // Use provides a mock function with given fields: f
 func (_m *RouterGroup) Use(f func(http.Handler) http.Handler) {
 	_m.Called(f)
 }
 
+// UseNamed provides a mock function with given fields: name, f
+func (_m *RouterGroup) UseNamed(name string, f func(http.Handler) http.Handler) {
+	_m.Called(name, f)
+}
+
+// UseBefore provides a mock function with given fields: before, name, f
+func (_m *RouterGroup) UseBefore(before string, name string, f func(http.Handler) http.Handler) {
+	_m.Called(before, name, f)
+}
+
+// UseAfter provides a mock function with given fields: after, name, f
+func (_m *RouterGroup) UseAfter(after string, name string, f func(http.Handler) http.Handler) {
+	_m.Called(after, name, f)
+}
+
+// Without provides a mock function with given fields: names
+func (_m *RouterGroup) Without(names ...string) {
+	_va := make([]interface{}, len(names))
+	for _i := range names {
+		_va[_i] = names[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, _va...)
+	_m.Called(_ca...)
+}
+
 // Chain provides a mock function with given fields:
 func (_m *RouterGroup) Chain() alice.Chain {
 	ret := _m.Called()
@@ -38,7 +69,9 @@ func (_m *RouterGroup) Group(path string) xrouter.RouterGroup {
 	if rf, ok := ret.Get(0).(func(string) xrouter.RouterGroup); ok {
 		r0 = rf(path)
 	} else {
-		r0 = ret.Get(0).(xrouter.RouterGroup)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(xrouter.RouterGroup)
+		}
 	}
 
 	return r0
@@ -58,37 +91,150 @@ func (_m *RouterGroup) Path() string {
 	return r0
 }
 
-// GET provides a mock function with given fields: path, handler
-func (_m *RouterGroup) GET(path string, handler xrouter.Route) {
-	_m.Called(path, handler)
+// Handle provides a mock function with given fields: method, path, handler, opts
+func (_m *RouterGroup) Handle(method string, path string, handler xrouter.Route, opts ...xrouter.RouteOption) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, method, path, handler)
+	_ca = append(_ca, _va...)
+	_m.Called(_ca...)
+}
+
+// GET provides a mock function with given fields: path, handler, opts
+func (_m *RouterGroup) GET(path string, handler xrouter.Route, opts ...xrouter.RouteOption) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, path, handler)
+	_ca = append(_ca, _va...)
+	_m.Called(_ca...)
+}
+
+// POST provides a mock function with given fields: path, handler, opts
+func (_m *RouterGroup) POST(path string, handler xrouter.Route, opts ...xrouter.RouteOption) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, path, handler)
+	_ca = append(_ca, _va...)
+	_m.Called(_ca...)
+}
+
+// PUT provides a mock function with given fields: path, handler, opts
+func (_m *RouterGroup) PUT(path string, handler xrouter.Route, opts ...xrouter.RouteOption) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, path, handler)
+	_ca = append(_ca, _va...)
+	_m.Called(_ca...)
+}
+
+// OPTIONS provides a mock function with given fields: path, handler, opts
+func (_m *RouterGroup) OPTIONS(path string, handler xrouter.Route, opts ...xrouter.RouteOption) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, path, handler)
+	_ca = append(_ca, _va...)
+	_m.Called(_ca...)
 }
 
-// POST provides a mock function with given fields: path, handler
-func (_m *RouterGroup) POST(path string, handler xrouter.Route) {
-	_m.Called(path, handler)
+// HEAD provides a mock function with given fields: path, handler, opts
+func (_m *RouterGroup) HEAD(path string, handler xrouter.Route, opts ...xrouter.RouteOption) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, path, handler)
+	_ca = append(_ca, _va...)
+	_m.Called(_ca...)
 }
 
-// PUT provides a mock function with given fields: path, handler
-func (_m *RouterGroup) PUT(path string, handler xrouter.Route) {
-	_m.Called(path, handler)
+// PATCH provides a mock function with given fields: path, handler, opts
+func (_m *RouterGroup) PATCH(path string, handler xrouter.Route, opts ...xrouter.RouteOption) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, path, handler)
+	_ca = append(_ca, _va...)
+	_m.Called(_ca...)
+}
+
+// DELETE provides a mock function with given fields: path, handler, opts
+func (_m *RouterGroup) DELETE(path string, handler xrouter.Route, opts ...xrouter.RouteOption) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, path, handler)
+	_ca = append(_ca, _va...)
+	_m.Called(_ca...)
+}
+
+// WebSocket provides a mock function with given fields: path, handler, opts
+func (_m *RouterGroup) WebSocket(path string, handler xrouter.WebSocketHandler, opts ...xrouter.RouteOption) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, path, handler)
+	_ca = append(_ca, _va...)
+	_m.Called(_ca...)
 }
 
-// OPTIONS provides a mock function with given fields: path, handler
-func (_m *RouterGroup) OPTIONS(path string, handler xrouter.Route) {
-	_m.Called(path, handler)
+// Proxy provides a mock function with given fields: path, target, opts
+func (_m *RouterGroup) Proxy(path string, target *url.URL, opts xrouter.ProxyOptions) {
+	_m.Called(path, target, opts)
 }
 
-// HEAD provides a mock function with given fields: path, handler
-func (_m *RouterGroup) HEAD(path string, handler xrouter.Route) {
-	_m.Called(path, handler)
+// NotFound provides a mock function with given fields: _a0
+func (_m *RouterGroup) NotFound(_a0 http.Handler) {
+	_m.Called(_a0)
 }
 
-// PATCH provides a mock function with given fields: path, handler
-func (_m *RouterGroup) PATCH(path string, handler xrouter.Route) {
-	_m.Called(path, handler)
+// MethodNotAllowed provides a mock function with given fields: _a0
+func (_m *RouterGroup) MethodNotAllowed(_a0 http.Handler) {
+	_m.Called(_a0)
 }
 
-// DELETE provides a mock function with given fields: path, handler
-func (_m *RouterGroup) DELETE(path string, handler xrouter.Route) {
-	_m.Called(path, handler)
+// Defaults provides a mock function with given fields: opts
+func (_m *RouterGroup) Defaults(opts ...xrouter.RouteOption) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, _va...)
+	_m.Called(_ca...)
+}
+
+// ShuttingDown provides a mock function with given fields:
+func (_m *RouterGroup) ShuttingDown() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
 }
@@ -0,0 +1,62 @@
+package xrouter
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// normalizePath applies the normalization to an escaped path.
+func (n PathNormalization) normalizePath(path string) string {
+	if n.CollapseSlashes {
+		for strings.Contains(path, "//") {
+			path = strings.ReplaceAll(path, "//", "/")
+		}
+	}
+	if n.StripTrailingSlash && len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+		if path == "" {
+			path = "/"
+		}
+	}
+	if n.Lowercase {
+		path = strings.ToLower(path)
+	}
+	return path
+}
+
+// normalizeHandler redirects or rewrites requests whose paths are not normalized.
+func normalizeHandler(n PathNormalization, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		escaped := req.URL.EscapedPath()
+		normalized := n.normalizePath(escaped)
+		if normalized == escaped {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		if n.Redirect != 0 {
+			// A target starting with // or /\ would be treated by browsers as a URL on another host.
+			target := "/" + strings.TrimLeft(normalized, "/\\")
+			if req.URL.RawQuery != "" {
+				target += "?" + req.URL.RawQuery
+			}
+			http.Redirect(w, req, target, n.Redirect)
+			return
+		}
+
+		path, err := url.PathUnescape(normalized)
+		if err != nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+		u := *req.URL
+		u.Path, u.RawPath = path, ""
+		if u.EscapedPath() != normalized {
+			u.RawPath = normalized
+		}
+		r2 := *req
+		r2.URL = &u
+		next.ServeHTTP(w, &r2)
+	})
+}
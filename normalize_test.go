@@ -0,0 +1,80 @@
+package xrouter
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func pathEcho(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(r.URL.Path + "?" + r.URL.RawQuery))
+}
+
+func TestRouterFlagOptions(t *testing.T) {
+	r := New()
+	r.GET("/users", pathEcho)
+	assert.Equal(t, http.StatusMovedPermanently, send(r, "GET", "/users/", nil).Code)
+	assert.Equal(t, http.StatusMovedPermanently, send(r, "GET", "/USERS", nil).Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, send(r, "POST", "/users", nil).Code)
+
+	r = New(RedirectTrailingSlash(false), RedirectFixedPath(false), HandleMethodNotAllowed(false))
+	r.GET("/users", pathEcho)
+	assert.Equal(t, http.StatusNotFound, send(r, "GET", "/users/", nil).Code)
+	assert.Equal(t, http.StatusNotFound, send(r, "GET", "/USERS", nil).Code)
+	assert.Equal(t, http.StatusNotFound, send(r, "POST", "/users", nil).Code)
+}
+
+func TestNormalizePathsRewrite(t *testing.T) {
+	r := New(RedirectTrailingSlash(false), RedirectFixedPath(false),
+		NormalizePaths(PathNormalization{CollapseSlashes: true, StripTrailingSlash: true, Lowercase: true}))
+	r.GET("/api/users", pathEcho)
+	r.GET("/", pathEcho)
+
+	w := send(r, "GET", "//API///Users/?page=2&Sort=Name", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "/api/users?page=2&Sort=Name", w.Body.String())
+
+	w = send(r, "GET", "///", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "/?", w.Body.String())
+}
+
+func TestNormalizePathsRedirect(t *testing.T) {
+	r := New(NormalizePaths(PathNormalization{CollapseSlashes: true, StripTrailingSlash: true, Redirect: http.StatusPermanentRedirect}))
+	r.GET("/api/users", pathEcho)
+
+	w := send(r, "POST", "/api//users/?q=a%20b", nil)
+	assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+	assert.Equal(t, "/api/users?q=a%20b", w.Header().Get("Location"))
+
+	w = send(r, "GET", "/api/users?q=1", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestNormalizePathsRedirectStaysOnHost(t *testing.T) {
+	for _, n := range []PathNormalization{
+		{StripTrailingSlash: true, Redirect: http.StatusMovedPermanently},
+		{Lowercase: true, Redirect: http.StatusMovedPermanently},
+	} {
+		r := New(NormalizePaths(n))
+		r.GET("/", pathEcho)
+
+		w := send(r, "GET", "//Evil.example/", nil)
+		assert.Equal(t, http.StatusMovedPermanently, w.Code)
+		location := w.Header().Get("Location")
+		assert.True(t, strings.HasPrefix(location, "/") && !strings.HasPrefix(location, "//"), location)
+	}
+}
+
+func TestNormalizePathsPreservesEscapes(t *testing.T) {
+	r := New(NormalizePaths(PathNormalization{CollapseSlashes: true}))
+	r.GET("/files/*name", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.EscapedPath()))
+	})
+
+	w := send(r, "GET", "//files/a%2Fb", nil)
+	assert.Equal(t, "/files/a%2Fb", w.Body.String())
+}
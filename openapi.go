@@ -0,0 +1,382 @@
+package xrouter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenAPIOptions describes the API as a whole for OpenAPI document generation.
+type OpenAPIOptions struct {
+	Title       string
+	Version     string
+	Description string
+
+	// SecuritySchemes maps scheme names, as used by the Security route option, to OpenAPI security scheme objects.
+	// For example: {"bearer": {"type": "http", "scheme": "bearer"}}.
+	SecuritySchemes map[string]map[string]interface{}
+}
+
+// OpenAPIDocument is a generated OpenAPI 3.1 document.
+type OpenAPIDocument map[string]interface{}
+
+// GenerateOpenAPI builds an OpenAPI 3.1 document from the given routes. Route paths are translated from httprouter
+// syntax to OpenAPI path templates and request and response schemas are derived from the Go types attached to each route.
+// Undocumented routes are skipped.
+func GenerateOpenAPI(opts OpenAPIOptions, routes []RouteInfo) OpenAPIDocument {
+	gen := &schemaGenerator{names: make(map[reflect.Type]string), schemas: make(map[string]interface{})}
+	gen.names[errorBodyType] = "Error"
+	gen.schemas["Error"] = gen.object(errorBodyType)
+
+	info := map[string]interface{}{"title": opts.Title, "version": opts.Version}
+	if opts.Description != "" {
+		info["description"] = opts.Description
+	}
+
+	paths := make(map[string]interface{})
+	for _, route := range routes {
+		if route.Undocumented {
+			continue
+		}
+		template, params := openAPIPath(route.Path)
+		item, ok := paths[template].(map[string]interface{})
+		if !ok {
+			item = make(map[string]interface{})
+			paths[template] = item
+		}
+		item[strings.ToLower(route.Method)] = gen.operation(route, params)
+	}
+
+	doc := OpenAPIDocument{
+		"openapi": "3.1.0",
+		"info":    info,
+		"paths":   paths,
+	}
+
+	components := map[string]interface{}{"schemas": gen.schemas}
+	if len(opts.SecuritySchemes) > 0 {
+		components["securitySchemes"] = opts.SecuritySchemes
+	}
+	doc["components"] = components
+	return doc
+}
+
+// JSON encodes the document as indented JSON.
+func (d OpenAPIDocument) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// YAML encodes the document as YAML.
+func (d OpenAPIDocument) YAML() ([]byte, error) {
+	// Round trip through JSON so that only maps, slices and scalars remain.
+	data, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	writeYAML(&buf, v, 0)
+	return buf.Bytes(), nil
+}
+
+// openAPIPath translates an httprouter path into an OpenAPI path template and returns the names of its parameters.
+func openAPIPath(path string) (string, []string) {
+	var params []string
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if len(segment) > 1 && (segment[0] == ':' || segment[0] == '*') {
+			params = append(params, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// constraintSchema returns the schema of a path parameter with the given constraint.
+func constraintSchema(constraint string) map[string]interface{} {
+	switch constraint {
+	case "":
+		return map[string]interface{}{"type": "string"}
+	case "int":
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case "uint":
+		return map[string]interface{}{"type": "integer", "format": "int64", "minimum": 0}
+	case "float":
+		return map[string]interface{}{"type": "number"}
+	case "bool":
+		return map[string]interface{}{"type": "boolean"}
+	case "uuid":
+		return map[string]interface{}{"type": "string", "format": "uuid"}
+	}
+	if named, ok := namedConstraints[constraint]; ok {
+		constraint = named
+	}
+	return map[string]interface{}{"type": "string", "pattern": "^(?:" + constraint + ")$"}
+}
+
+// openAPIHandler serves the OpenAPI document for the router, generating it on each request so that it is always current.
+func openAPIHandler(r *router, path string, opts OpenAPIOptions) Route {
+	asYAML := strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml")
+	return func(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+		doc := GenerateOpenAPI(opts, r.Routes())
+
+		var data []byte
+		var err error
+		if asYAML {
+			w.Header().Set("Content-Type", "application/yaml")
+			data, err = doc.YAML()
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+			data, err = doc.JSON()
+		}
+		if err != nil {
+			w.Header().Del("Content-Type")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(data)
+	}
+}
+
+// schemaGenerator converts Go types into JSON schemas, collecting named struct types as reusable components.
+type schemaGenerator struct {
+	names   map[reflect.Type]string
+	schemas map[string]interface{}
+}
+
+func (g *schemaGenerator) operation(route RouteInfo, params []string) map[string]interface{} {
+	op := make(map[string]interface{})
+	if route.Summary != "" {
+		op["summary"] = route.Summary
+	}
+	if route.Description != "" {
+		op["description"] = route.Description
+	}
+	if len(route.Tags) > 0 {
+		op["tags"] = route.Tags
+	}
+	if route.Deprecation != nil {
+		op["deprecated"] = true
+	}
+	if len(route.Security) > 0 {
+		var security []interface{}
+		for _, name := range route.Security {
+			security = append(security, map[string]interface{}{name: []string{}})
+		}
+		op["security"] = security
+	}
+	if len(route.Metadata) > 0 {
+		op["x-metadata"] = route.Metadata
+	}
+
+	if len(params) > 0 {
+		var parameters []interface{}
+		for _, name := range params {
+			parameters = append(parameters, map[string]interface{}{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"schema":   constraintSchema(route.Constraints[name]),
+			})
+		}
+		op["parameters"] = parameters
+	}
+
+	if !isEmptyType(route.Request) {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": g.schema(route.Request)}},
+		}
+	}
+
+	responses := make(map[string]interface{})
+	if route.Response == nil {
+		responses["200"] = map[string]interface{}{"description": http.StatusText(http.StatusOK)}
+	} else {
+		status := successStatus(route.Method, route.Response)
+		resp := map[string]interface{}{"description": http.StatusText(status)}
+		if status != http.StatusNoContent {
+			resp["content"] = map[string]interface{}{"application/json": map[string]interface{}{"schema": g.schema(route.Response)}}
+		}
+		responses[strconv.Itoa(status)] = resp
+		responses["default"] = map[string]interface{}{
+			"description": "Error",
+			"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": g.schema(errorBodyType)}},
+		}
+	}
+	op["responses"] = responses
+	return op
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	errorBodyType = reflect.TypeOf(errorBody{})
+)
+
+// schema returns the JSON schema for t. Named structs are added to the components and referenced.
+func (g *schemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return map[string]interface{}{"type": "string", "format": "byte"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		name, ok := g.names[t]
+		if !ok {
+			name = g.componentName(t)
+			g.names[t] = name
+			g.schemas[name] = map[string]interface{}{}
+			g.schemas[name] = g.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+// componentName returns a unique component name for a named type.
+func (g *schemaGenerator) componentName(t reflect.Type) string {
+	name := t.Name()
+	if _, taken := g.schemas[name]; !taken {
+		return name
+	}
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	return pkg + "." + name
+}
+
+// object returns the JSON schema for a struct using the same field names as encoding/json.
+func (g *schemaGenerator) object(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+	g.fields(t, properties, &required)
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (g *schemaGenerator) fields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, flags, _ := strings.Cut(tag, ",")
+
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			g.fields(ft, properties, required)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		properties[name] = g.schema(f.Type)
+		if !strings.Contains(flags, "omitempty") && f.Type.Kind() != reflect.Ptr {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// writeYAML writes a value decoded from JSON as block style YAML. Strings and keys are written as JSON strings,
+// which are valid double quoted YAML scalars.
+func writeYAML(buf *bytes.Buffer, v interface{}, indent int) {
+	pad := strings.Repeat(" ", indent)
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			key, _ := json.Marshal(k)
+			buf.WriteString(pad)
+			buf.Write(key)
+			buf.WriteString(":")
+			writeYAMLValue(buf, v[k], indent)
+		}
+	case []interface{}:
+		for _, item := range v {
+			buf.WriteString(pad)
+			buf.WriteString("-")
+			writeYAMLValue(buf, item, indent)
+		}
+	}
+}
+
+func writeYAMLValue(buf *bytes.Buffer, v interface{}, indent int) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if len(val) == 0 {
+			buf.WriteString(" {}\n")
+			return
+		}
+		buf.WriteString("\n")
+		writeYAML(buf, val, indent+2)
+	case []interface{}:
+		if len(val) == 0 {
+			buf.WriteString(" []\n")
+			return
+		}
+		buf.WriteString("\n")
+		writeYAML(buf, val, indent+2)
+	case nil:
+		buf.WriteString(" null\n")
+	case string:
+		s, _ := json.Marshal(val)
+		buf.WriteString(" ")
+		buf.Write(s)
+		buf.WriteString("\n")
+	case float64:
+		buf.WriteString(" " + strconv.FormatFloat(val, 'f', -1, 64) + "\n")
+	case bool:
+		buf.WriteString(" " + strconv.FormatBool(val) + "\n")
+	}
+}
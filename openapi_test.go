@@ -0,0 +1,100 @@
+package xrouter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPIPath(t *testing.T) {
+	path, params := openAPIPath("/api/v1/apps/:app/clients/users/:userid/info")
+	assert.Equal(t, "/api/v1/apps/{app}/clients/users/{userid}/info", path)
+	assert.Equal(t, []string{"app", "userid"}, params)
+
+	path, params = openAPIPath("/files/*filepath")
+	assert.Equal(t, "/files/{filepath}", path)
+	assert.Equal(t, []string{"filepath"}, params)
+}
+
+func TestGenerateOpenAPI(t *testing.T) {
+	r := newJSONRouter()
+	r.GET("/health", GetTest, Summary("Health check"), Tags("ops"))
+
+	doc := GenerateOpenAPI(OpenAPIOptions{Title: "Users", Version: "1.0.0"}, r.Routes())
+	data, err := doc.JSON()
+	assert.NoError(t, err)
+
+	var v struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			Summary     string   `json:"summary"`
+			Tags        []string `json:"tags"`
+			Parameters  []map[string]interface{}
+			RequestBody map[string]interface{}
+			Responses   map[string]interface{}
+		}
+		Components struct {
+			Schemas map[string]map[string]interface{}
+		}
+	}
+	assert.NoError(t, json.Unmarshal(data, &v))
+	assert.Equal(t, "3.1.0", v.OpenAPI)
+
+	post := v.Paths["/api/users"]["post"]
+	assert.NotNil(t, post.RequestBody)
+	assert.Contains(t, post.Responses, "201")
+	assert.Contains(t, post.Responses, "default")
+
+	get := v.Paths["/api/users/{id}"]["get"]
+	assert.Nil(t, get.RequestBody)
+	assert.Equal(t, 1, len(get.Parameters))
+	assert.Equal(t, "id", get.Parameters[0]["name"])
+
+	assert.Contains(t, v.Paths["/api/users/{id}"]["delete"].Responses, "204")
+	assert.Equal(t, "Health check", v.Paths["/health"]["get"].Summary)
+	assert.Equal(t, []string{"ops"}, v.Paths["/health"]["get"].Tags)
+
+	assert.Contains(t, v.Components.Schemas, "createUser")
+	assert.Equal(t, []interface{}{"id", "name"}, v.Components.Schemas["user"]["required"])
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	r := newJSONRouter()
+	r.ServeOpenAPI("/openapi.json", OpenAPIOptions{Title: "Users", Version: "1.0.0"})
+	r.ServeOpenAPI("/openapi.yaml", OpenAPIOptions{Title: "Users", Version: "1.0.0"})
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.False(t, strings.Contains(w.Body.String(), "/openapi.json"))
+
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/openapi.yaml", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/yaml", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "\"openapi\": \"3.1.0\"\n")
+	assert.Contains(t, w.Body.String(), "\"/api/users/{id}\":\n")
+}
+
+func TestServeOpenAPIIsRegisteredRoute(t *testing.T) {
+	r := newJSONRouter()
+	r.UseNamed("log", traceMiddleware("log"))
+	r.ServeOpenAPI("/openapi.json", OpenAPIOptions{Title: "Users", Version: "1.0.0"})
+
+	// The document route runs the router's middleware and is listed, but not documented.
+	assert.Equal(t, "log", trace(r, "/openapi.json"))
+	info, _, ok := r.Lookup("GET", "/openapi.json")
+	assert.True(t, ok)
+	assert.True(t, info.Undocumented)
+	assert.NotContains(t, GenerateOpenAPI(OpenAPIOptions{}, r.Routes())["paths"], "/openapi.json")
+
+	// Registering it twice is a route conflict.
+	assert.Panics(t, func() {
+		r.ServeOpenAPI("/openapi.json", OpenAPIOptions{})
+	})
+}
@@ -0,0 +1,59 @@
+package xrouter
+
+// Option configures a router when it is created.
+type Option func(*router)
+
+// CollectErrors makes the router collect route registration errors, such as conflicting wildcards or duplicate
+// routes, instead of panicking. The collected errors are returned by Router.Validate and offending routes are skipped.
+func CollectErrors() Option {
+	return func(r *router) {
+		r.group.table.collect = true
+	}
+}
+
+// RedirectTrailingSlash controls whether requests for a path with or without a trailing slash are redirected when only
+// the other form has a route. Enabled by default.
+func RedirectTrailingSlash(enabled bool) Option {
+	return func(r *router) {
+		r.router.RedirectTrailingSlash = enabled
+	}
+}
+
+// RedirectFixedPath controls whether requests are redirected to a route which matches the cleaned, case-insensitive
+// path when no route matches exactly. Enabled by default.
+func RedirectFixedPath(enabled bool) Option {
+	return func(r *router) {
+		r.router.RedirectFixedPath = enabled
+	}
+}
+
+// HandleMethodNotAllowed controls whether requests matching a route for another method are answered with 405 Method
+// Not Allowed instead of 404 Not Found. Enabled by default.
+func HandleMethodNotAllowed(enabled bool) Option {
+	return func(r *router) {
+		r.router.HandleMethodNotAllowed = enabled
+	}
+}
+
+// PathNormalization configures how request paths are normalized before routing.
+type PathNormalization struct {
+	// CollapseSlashes replaces runs of slashes with a single slash.
+	CollapseSlashes bool
+
+	// StripTrailingSlash removes the trailing slash from every path except the root.
+	StripTrailingSlash bool
+
+	// Lowercase converts paths to lower case. Parameter values are lowercased as well.
+	Lowercase bool
+
+	// Redirect is the status used to redirect clients to the normalized path, either 301 Moved Permanently or 308
+	// Permanent Redirect. When zero, requests are rewritten and routed without a redirect.
+	Redirect int
+}
+
+// NormalizePaths normalizes request paths before they are routed. Query strings are preserved.
+func NormalizePaths(n PathNormalization) Option {
+	return func(r *router) {
+		r.normalize = &n
+	}
+}
@@ -0,0 +1,300 @@
+package xrouter
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProxyOptions configures a reverse proxy route. Zero values use the defaults listed for each field.
+type ProxyOptions struct {
+	// Targets lists additional upstreams. Requests are balanced round-robin across the primary target and these.
+	Targets []*url.URL
+
+	// PreservePath forwards the full request path instead of the part matched by the route's catch-all parameter.
+	PreservePath bool
+
+	// Rewrite modifies the path forwarded to the upstream, after the prefix has been stripped. The path is decoded, so a
+	// path it changes is escaped again and encoded slashes in it reach the upstream as plain slashes.
+	Rewrite func(path string) string
+
+	// RequestHeaders and ResponseHeaders set headers on the upstream request and on the response. An empty value
+	// removes the header.
+	RequestHeaders  map[string]string
+	ResponseHeaders map[string]string
+
+	// PreserveHost forwards the client's Host header instead of the upstream's host.
+	PreserveHost bool
+
+	// TrustForwarded keeps X-Forwarded-For from the client and appends to it. Otherwise the header is replaced.
+	TrustForwarded bool
+
+	// Timeout limits how long to wait for the upstream's response headers on each attempt. Defaults to 30 seconds.
+	Timeout time.Duration
+
+	// DialTimeout limits how long connecting to an upstream may take. Defaults to 10 seconds.
+	DialTimeout time.Duration
+
+	// Retries is the number of times requests with idempotent methods and no body are retried on another upstream
+	// after a connection error or a 502, 503 or 504 response.
+	Retries int
+
+	// FailureThreshold is the number of consecutive failures after which an upstream is skipped. Defaults to 3.
+	FailureThreshold int
+
+	// Cooldown is how long a failing upstream is skipped before it is tried again. Defaults to 30 seconds.
+	Cooldown time.Duration
+
+	// Transport is used to reach the upstreams. Defaults to a transport with the timeouts above.
+	Transport http.RoundTripper
+}
+
+func (o *ProxyOptions) setDefaults() {
+	if o.Timeout == 0 {
+		o.Timeout = 30 * time.Second
+	}
+	if o.DialTimeout == 0 {
+		o.DialTimeout = 10 * time.Second
+	}
+	if o.FailureThreshold == 0 {
+		o.FailureThreshold = 3
+	}
+	if o.Cooldown == 0 {
+		o.Cooldown = 30 * time.Second
+	}
+	if o.Transport == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.DialContext = (&net.Dialer{Timeout: o.DialTimeout, KeepAlive: 30 * time.Second}).DialContext
+		t.ResponseHeaderTimeout = o.Timeout
+		o.Transport = t
+	}
+}
+
+// proxyMethods are the methods registered for proxy routes.
+var proxyMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// Proxy forwards every request under the path to the target. A catch-all parameter is appended to the path unless it
+// already ends with one, and only the part of the path it matches is forwarded.
+func (r *routerGroup) Proxy(path string, target *url.URL, opts ProxyOptions) {
+	route := ProxyRoute(target, opts)
+	if !strings.Contains(path, "*") {
+		path = strings.TrimSuffix(path, "/") + "/*proxypath"
+	}
+	upstreams := []string{target.String()}
+	for _, t := range opts.Targets {
+		upstreams = append(upstreams, t.String())
+	}
+	for _, method := range proxyMethods {
+		r.Handle(method, path, route, func(info *RouteInfo) {
+			info.Upstreams = upstreams
+		})
+	}
+}
+
+// ProxyRoute returns a route which forwards requests to the target using httputil.ReverseProxy. The group's middleware
+// runs before requests are forwarded, and WebSocket upgrades are passed through.
+func ProxyRoute(target *url.URL, opts ProxyOptions) Route {
+	opts.setDefaults()
+	b := &balancer{opts: &opts, transport: opts.Transport}
+	for _, t := range append([]*url.URL{target}, opts.Targets...) {
+		b.upstreams = append(b.upstreams, &upstream{url: t})
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Transport: b,
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			if opts.TrustForwarded {
+				pr.Out.Header["X-Forwarded-For"] = pr.In.Header["X-Forwarded-For"]
+			}
+			pr.SetXForwarded()
+
+			path, rawPath := pr.In.URL.Path, pr.In.URL.EscapedPath()
+			if !opts.PreservePath {
+				if rest, ok := proxyPath(pr.In.Context()); ok {
+					prefix := strings.TrimSuffix(path, rest)
+					if prefix != "" {
+						pr.Out.Header.Set("X-Forwarded-Prefix", prefix)
+					}
+					path, rawPath = rest, escapedTail(rawPath, len(prefix))
+				}
+			}
+			if opts.Rewrite != nil {
+				if rewritten := opts.Rewrite(path); rewritten != path {
+					path, rawPath = rewritten, ""
+				}
+			}
+			// RawPath keeps the client's escaping, such as %2F within a segment. It is ignored if it does not encode Path.
+			pr.Out.URL.Path, pr.Out.URL.RawPath = path, rawPath
+			if !opts.PreserveHost {
+				pr.Out.Host = ""
+			}
+			for name, value := range opts.RequestHeaders {
+				setOrDelete(pr.Out.Header, name, value)
+			}
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			for name, value := range opts.ResponseHeaders {
+				setOrDelete(resp.Header, name, value)
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			var netErr net.Error
+			if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+				WriteError(w, http.StatusGatewayTimeout, "upstream timed out")
+				return
+			}
+			WriteError(w, http.StatusBadGateway, "upstream unavailable")
+		},
+	}
+
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		proxy.ServeHTTP(w, r)
+	}
+}
+
+// escapedTail returns the part of an escaped path which follows the first n bytes of its decoded form.
+func escapedTail(escaped string, n int) string {
+	i := 0
+	for ; n > 0 && i < len(escaped); n-- {
+		if escaped[i] == '%' {
+			i += 3
+		} else {
+			i++
+		}
+	}
+	return escaped[min(i, len(escaped)):]
+}
+
+// proxyPath returns the value of the route's catch-all parameter.
+func proxyPath(ctx context.Context) (string, bool) {
+	info, ok := RouteFromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	i := strings.LastIndex(info.Path, "/*")
+	if i < 0 {
+		return "", false
+	}
+	rest := Param(ctx, info.Path[i+2:])
+	if rest == "" {
+		rest = "/"
+	}
+	return rest, true
+}
+
+func setOrDelete(h http.Header, name, value string) {
+	if value == "" {
+		h.Del(name)
+		return
+	}
+	h.Set(name, value)
+}
+
+// upstream is a proxy target with its passive health state.
+type upstream struct {
+	url *url.URL
+
+	mu        sync.Mutex
+	failures  int
+	downUntil time.Time
+}
+
+func (u *upstream) healthy(now time.Time) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return !now.Before(u.downUntil)
+}
+
+// report records the outcome of a request, skipping the upstream once it has failed too often in a row.
+func (u *upstream) report(ok bool, opts *ProxyOptions) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if ok {
+		u.failures = 0
+		return
+	}
+	u.failures++
+	if u.failures >= opts.FailureThreshold {
+		u.downUntil = time.Now().Add(opts.Cooldown)
+		u.failures = 0
+	}
+}
+
+// balancer is a RoundTripper which sends each attempt to the next healthy upstream.
+type balancer struct {
+	opts      *ProxyOptions
+	transport http.RoundTripper
+	upstreams []*upstream
+	next      atomic.Uint64
+}
+
+// pick returns the next healthy upstream in round-robin order, or the next upstream if none are healthy.
+func (b *balancer) pick() *upstream {
+	start := b.next.Add(1) - 1
+	now := time.Now()
+	for i := 0; i < len(b.upstreams); i++ {
+		u := b.upstreams[(start+uint64(i))%uint64(len(b.upstreams))]
+		if u.healthy(now) {
+			return u
+		}
+	}
+	return b.upstreams[start%uint64(len(b.upstreams))]
+}
+
+func (b *balancer) RoundTrip(req *http.Request) (*http.Response, error) {
+	attempts := 1
+	if retryable(req) {
+		attempts += b.opts.Retries
+	}
+
+	var resp *http.Response
+	var err error
+	for i := 0; i < attempts; i++ {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		u := b.pick()
+		resp, err = b.transport.RoundTrip(upstreamRequest(req, u.url))
+		failed := err != nil || resp.StatusCode == http.StatusBadGateway ||
+			resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode == http.StatusGatewayTimeout
+		u.report(!failed, b.opts)
+		if !failed || req.Context().Err() != nil {
+			break
+		}
+	}
+	return resp, err
+}
+
+// retryable reports whether a request can be safely sent again.
+func retryable(req *http.Request) bool {
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return req.Body == nil || req.Body == http.NoBody
+	}
+	return false
+}
+
+// upstreamRequest addresses a request to an upstream, joining the upstream's path and query with the request's.
+func upstreamRequest(req *http.Request, target *url.URL) *http.Request {
+	out := req.Clone(req.Context())
+	out.URL.Scheme = target.Scheme
+	out.URL.Host = target.Host
+	out.URL.Path = strings.TrimSuffix(target.Path, "/") + "/" + strings.TrimPrefix(req.URL.Path, "/")
+	out.URL.RawPath = strings.TrimSuffix(target.EscapedPath(), "/") + "/" + strings.TrimPrefix(req.URL.EscapedPath(), "/")
+	if target.RawQuery != "" {
+		if out.URL.RawQuery == "" {
+			out.URL.RawQuery = target.RawQuery
+		} else {
+			out.URL.RawQuery = target.RawQuery + "&" + out.URL.RawQuery
+		}
+	}
+	return out
+}
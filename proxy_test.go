@@ -0,0 +1,191 @@
+package xrouter
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newUpstream(name string, status *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != nil && status.Load() != 0 {
+			w.WriteHeader(int(status.Load()))
+			return
+		}
+		w.Header().Set("X-Upstream", name)
+		w.Header().Set("Server", "legacy")
+		w.Header().Set("X-Path", r.URL.Path)
+		w.Header().Set("X-Raw-Path", r.URL.EscapedPath())
+		w.Header().Set("X-Query", r.URL.RawQuery)
+		w.Header().Set("X-Host", r.Host)
+		w.Header().Set("X-Got-For", r.Header.Get("X-Forwarded-For"))
+		w.Header().Set("X-Got-Prefix", r.Header.Get("X-Forwarded-Prefix"))
+		w.Header().Set("X-Got-Token", r.Header.Get("X-Token"))
+		w.Header().Set("X-Got-Cookie", r.Header.Get("Cookie"))
+		io.WriteString(w, name)
+	}))
+}
+
+func mustParse(t *testing.T, raw string) *url.URL {
+	u, err := url.Parse(raw)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return u
+}
+
+func TestProxyRewritesRequests(t *testing.T) {
+	up := newUpstream("a", nil)
+	defer up.Close()
+
+	r := New()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("X-Middleware", "yes")
+			next.ServeHTTP(w, req)
+		})
+	})
+	r.Group("/legacy").Proxy("/billing", mustParse(t, up.URL+"/api?v=1"), ProxyOptions{
+		RequestHeaders:  map[string]string{"X-Token": "secret", "Cookie": ""},
+		ResponseHeaders: map[string]string{"Server": ""},
+	})
+	r.Proxy("/raw", mustParse(t, up.URL), ProxyOptions{
+		PreservePath: true,
+		PreserveHost: true,
+		Rewrite:      func(path string) string { return path + "/x" },
+	})
+
+	req := httptest.NewRequest("GET", "/legacy/billing/invoices/7?page=2", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("Cookie", "session=1")
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "yes", w.Header().Get("X-Middleware"))
+	assert.Equal(t, "/api/invoices/7", w.Header().Get("X-Path"))
+	assert.Equal(t, "v=1&page=2", w.Header().Get("X-Query"))
+	assert.Equal(t, mustParse(t, up.URL).Host, w.Header().Get("X-Host"))
+	assert.Equal(t, "10.0.0.1", w.Header().Get("X-Got-For"))
+	assert.Equal(t, "/legacy/billing", w.Header().Get("X-Got-Prefix"))
+	assert.Equal(t, "secret", w.Header().Get("X-Got-Token"))
+	assert.Empty(t, w.Header().Get("X-Got-Cookie"))
+	assert.Empty(t, w.Header().Get("Server"))
+
+	req = httptest.NewRequest("POST", "/raw/a", nil)
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, "/raw/a/x", w.Header().Get("X-Path"))
+	assert.Equal(t, "example.com", w.Header().Get("X-Host"))
+
+	// Encoded slashes are forwarded as they were sent rather than splitting the segment.
+	w = send(r, "GET", "/legacy/billing/files/a%2Fb%20c", nil)
+	assert.Equal(t, "/api/files/a/b c", w.Header().Get("X-Path"))
+	assert.Equal(t, "/api/files/a%2Fb%20c", w.Header().Get("X-Raw-Path"))
+	w = send(r, "GET", "/raw/a%2Fb", nil)
+	assert.Equal(t, "/raw/a/b/x", w.Header().Get("X-Raw-Path"))
+
+	info, _, ok := r.Lookup("DELETE", "/legacy/billing/x")
+	assert.True(t, ok)
+	assert.Equal(t, []string{up.URL + "/api?v=1"}, info.Upstreams)
+}
+
+func TestProxyTrustForwarded(t *testing.T) {
+	up := newUpstream("a", nil)
+	defer up.Close()
+
+	r := New()
+	r.Proxy("/", mustParse(t, up.URL), ProxyOptions{TrustForwarded: true})
+
+	req := httptest.NewRequest("GET", "/x", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	assert.Equal(t, "1.2.3.4, 10.0.0.1", w.Header().Get("X-Got-For"))
+}
+
+func TestProxyBalancesAndRetries(t *testing.T) {
+	var failing atomic.Int32
+	a := newUpstream("a", &failing)
+	defer a.Close()
+	b := newUpstream("b", nil)
+	defer b.Close()
+
+	r := New()
+	r.Proxy("/svc", mustParse(t, a.URL), ProxyOptions{
+		Targets:          []*url.URL{mustParse(t, b.URL)},
+		Retries:          1,
+		FailureThreshold: 2,
+		Cooldown:         time.Hour,
+	})
+	call := func(method string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.Handler().ServeHTTP(w, httptest.NewRequest(method, "/svc/x", nil))
+		return w
+	}
+
+	assert.Equal(t, "a", call("GET").Body.String())
+	assert.Equal(t, "b", call("GET").Body.String())
+
+	// Idempotent requests are retried on the next upstream, other requests are not.
+	failing.Store(http.StatusServiceUnavailable)
+	w := call("GET")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "b", w.Body.String())
+	assert.Equal(t, http.StatusServiceUnavailable, call("POST").Code)
+
+	// a has now failed twice in a row and is skipped until the cooldown ends.
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "b", call("POST").Body.String())
+	}
+}
+
+func TestProxyErrors(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	r := New()
+	r.Proxy("/slow", mustParse(t, slow.URL), ProxyOptions{Timeout: 20 * time.Millisecond})
+	r.Proxy("/down", mustParse(t, down.URL), ProxyOptions{})
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/slow/x", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/down/x", nil))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+}
+
+func TestProxyWebSocket(t *testing.T) {
+	up := newWebSocketServer()
+	defer up.Close()
+
+	r := New()
+	r.Proxy("/ws", mustParse(t, up.URL), ProxyOptions{})
+	srv := httptest.NewServer(r.Handler())
+	defer srv.Close()
+
+	c, resp := dialWebSocket(t, srv, "/ws/echo", nil)
+	defer c.conn.Close()
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	c.writeFrame(true, opText, []byte("through the proxy"))
+	op, payload, err := c.readFrame()
+	assert.NoError(t, err)
+	assert.Equal(t, byte(opText), op)
+	assert.Equal(t, "through the proxy", string(payload))
+}
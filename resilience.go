@@ -0,0 +1,502 @@
+package xrouter
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Introspectable is implemented by middleware whose state is reported by IntrospectionHandler.
+type Introspectable interface {
+	Name() string
+	Introspect() interface{}
+}
+
+// IntrospectionHandler returns a route which reports the state of each component as a JSON object keyed by name.
+//
+//	db := xrouter.NewBulkhead("db", xrouter.BulkheadOptions{MaxConcurrent: 20, MaxQueue: 50})
+//	api.UseNamed("db-bulkhead", db.Middleware)
+//	r.GET("/debug/resilience", xrouter.IntrospectionHandler(db))
+func IntrospectionHandler(components ...Introspectable) Route {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		state := make(map[string]interface{}, len(components))
+		for _, c := range components {
+			state[c.Name()] = c.Introspect()
+		}
+		WriteJSON(w, http.StatusOK, state)
+	}
+}
+
+// partitionKey returns the route a request is counted against, or an empty string when the state is shared by every
+// route.
+func partitionKey(r *http.Request, perRoute bool) string {
+	if perRoute {
+		if info, ok := RouteFromContext(r.Context()); ok {
+			return info.Method + " " + info.Path
+		}
+	}
+	return ""
+}
+
+// retryAfter formats a duration as a Retry-After header value, rounding up to at least one second.
+func retryAfter(d time.Duration) string {
+	secs := int64((d + time.Second - 1) / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	return strconv.FormatInt(secs, 10)
+}
+
+// BulkheadOptions configures a Bulkhead.
+type BulkheadOptions struct {
+	// MaxConcurrent is the number of requests which may be handled at once. Defaults to 10.
+	MaxConcurrent int
+
+	// MaxQueue is the number of requests which may wait for a slot. Requests beyond it are rejected immediately.
+	MaxQueue int
+
+	// QueueTimeout is how long a queued request waits for a slot before it is rejected. Defaults to one second.
+	QueueTimeout time.Duration
+
+	// PerRoute limits each route separately instead of sharing the limit between every route using the middleware.
+	PerRoute bool
+
+	// RetryAfter is sent in the Retry-After header of rejected requests. Defaults to one second.
+	RetryAfter time.Duration
+
+	// Events receives a BulkheadRejectedEvent for each rejected request.
+	Events func(Event)
+}
+
+// Bulkhead limits the number of requests handled concurrently, queueing the requests beyond the limit for a short
+// time and rejecting the rest with 503 Service Unavailable.
+type Bulkhead struct {
+	name string
+	opts BulkheadOptions
+
+	mu         sync.Mutex
+	partitions map[string]*bulkheadPartition
+}
+
+type bulkheadPartition struct {
+	slots    chan struct{}
+	queued   atomic.Int64
+	rejected atomic.Uint64
+}
+
+// BulkheadStats reports the state of a Bulkhead for a route, or for every route when Route is empty.
+type BulkheadStats struct {
+	Route    string `json:"route,omitempty"`
+	InFlight int    `json:"in_flight"`
+	Queued   int64  `json:"queued"`
+	Rejected uint64 `json:"rejected"`
+}
+
+// NewBulkhead creates a bulkhead. The name identifies it in events and introspection.
+func NewBulkhead(name string, opts BulkheadOptions) *Bulkhead {
+	if opts.MaxConcurrent <= 0 {
+		opts.MaxConcurrent = 10
+	}
+	if opts.QueueTimeout == 0 {
+		opts.QueueTimeout = time.Second
+	}
+	if opts.RetryAfter == 0 {
+		opts.RetryAfter = time.Second
+	}
+	return &Bulkhead{name: name, opts: opts, partitions: make(map[string]*bulkheadPartition)}
+}
+
+// Name returns the name of the bulkhead.
+func (b *Bulkhead) Name() string {
+	return b.name
+}
+
+// Middleware limits the concurrency of the wrapped handler.
+func (b *Bulkhead) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := partitionKey(r, b.opts.PerRoute)
+		p := b.partition(key)
+		if reason := p.acquire(r.Context(), &b.opts); reason != "" {
+			p.rejected.Add(1)
+			if b.opts.Events != nil {
+				b.opts.Events(BulkheadRejectedEvent{Name: b.name, Route: key, Reason: reason})
+			}
+			w.Header().Set("Retry-After", retryAfter(b.opts.RetryAfter))
+			WriteError(w, http.StatusServiceUnavailable, "too many concurrent requests")
+			return
+		}
+		defer func() { <-p.slots }()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Stats returns the state of each partition of the bulkhead, sorted by route.
+func (b *Bulkhead) Stats() []BulkheadStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := make([]BulkheadStats, 0, len(b.partitions))
+	for key, p := range b.partitions {
+		stats = append(stats, BulkheadStats{key, len(p.slots), p.queued.Load(), p.rejected.Load()})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Route < stats[j].Route })
+	return stats
+}
+
+// Introspect returns the result of Stats.
+func (b *Bulkhead) Introspect() interface{} {
+	return b.Stats()
+}
+
+func (b *Bulkhead) partition(key string) *bulkheadPartition {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p, ok := b.partitions[key]
+	if !ok {
+		p = &bulkheadPartition{slots: make(chan struct{}, b.opts.MaxConcurrent)}
+		b.partitions[key] = p
+	}
+	return p
+}
+
+// acquire takes a slot, waiting in the queue if there is room. It returns the reason the request was rejected, if it
+// was.
+func (p *bulkheadPartition) acquire(ctx context.Context, opts *BulkheadOptions) string {
+	select {
+	case p.slots <- struct{}{}:
+		return ""
+	default:
+	}
+
+	if p.queued.Add(1) > int64(opts.MaxQueue) {
+		p.queued.Add(-1)
+		return "queue full"
+	}
+	defer p.queued.Add(-1)
+
+	timer := time.NewTimer(opts.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case p.slots <- struct{}{}:
+		return ""
+	case <-timer.C:
+		return "queue timeout"
+	case <-ctx.Done():
+		return "canceled"
+	}
+}
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+// Circuit breaker states. A closed circuit lets requests through, an open circuit rejects them and a half-open circuit
+// lets a few trial requests through to decide whether to close again.
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+// String returns the name of the state.
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// MarshalText encodes the state as its name.
+func (s CircuitState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// CircuitBreakerOptions configures a CircuitBreaker.
+type CircuitBreakerOptions struct {
+	// Window is the period over which error and latency rates are measured. Defaults to 10 seconds.
+	Window time.Duration
+
+	// MinRequests is the number of requests needed in the window before the circuit can trip. Defaults to 20.
+	MinRequests int
+
+	// ErrorRate is the share of failed requests, from 0 to 1, which trips the circuit. Defaults to 0.5.
+	ErrorRate float64
+
+	// SlowThreshold is the duration after which a request counts as slow. Latency is ignored when zero.
+	SlowThreshold time.Duration
+
+	// SlowRate is the share of slow requests, from 0 to 1, which trips the circuit. Defaults to 0.5.
+	SlowRate float64
+
+	// OpenFor is how long the circuit stays open before trial requests are let through. Defaults to 30 seconds.
+	OpenFor time.Duration
+
+	// HalfOpenRequests is the number of trial requests which must succeed to close the circuit. Defaults to 1.
+	HalfOpenRequests int
+
+	// IsFailure decides whether a response status is a failure. Defaults to statuses of 500 and above. Panics are
+	// always failures.
+	IsFailure func(status int) bool
+
+	// PerRoute keeps a separate circuit for each route instead of sharing one between every route using the
+	// middleware.
+	PerRoute bool
+
+	// Events receives a CircuitStateEvent each time a circuit changes state.
+	Events func(Event)
+}
+
+// circuitBuckets is the number of buckets the window is divided into.
+const circuitBuckets = 10
+
+// CircuitBreaker stops calling a handler whose error rate or latency is too high, answering with 503 Service
+// Unavailable and a Retry-After header until the circuit closes again.
+type CircuitBreaker struct {
+	name string
+	opts CircuitBreakerOptions
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	mu        sync.Mutex
+	state     CircuitState
+	buckets   [circuitBuckets]circuitBucket
+	openUntil time.Time
+	trials    int
+	successes int
+	trips     uint64
+	rejected  uint64
+}
+
+type circuitBucket struct {
+	start    time.Time
+	requests int
+	failures int
+	slow     int
+}
+
+// CircuitStats reports the state of a circuit for a route, or for every route when Route is empty. The request counts
+// cover the current window.
+type CircuitStats struct {
+	Route     string       `json:"route,omitempty"`
+	State     CircuitState `json:"state"`
+	Requests  int          `json:"requests"`
+	Failures  int          `json:"failures"`
+	Slow      int          `json:"slow"`
+	Trips     uint64       `json:"trips"`
+	Rejected  uint64       `json:"rejected"`
+	OpenUntil *time.Time   `json:"open_until,omitempty"`
+}
+
+// NewCircuitBreaker creates a circuit breaker. The name identifies it in events and introspection.
+func NewCircuitBreaker(name string, opts CircuitBreakerOptions) *CircuitBreaker {
+	if opts.Window == 0 {
+		opts.Window = 10 * time.Second
+	}
+	if opts.MinRequests == 0 {
+		opts.MinRequests = 20
+	}
+	if opts.ErrorRate == 0 {
+		opts.ErrorRate = 0.5
+	}
+	if opts.SlowRate == 0 {
+		opts.SlowRate = 0.5
+	}
+	if opts.OpenFor == 0 {
+		opts.OpenFor = 30 * time.Second
+	}
+	if opts.HalfOpenRequests == 0 {
+		opts.HalfOpenRequests = 1
+	}
+	if opts.IsFailure == nil {
+		opts.IsFailure = func(status int) bool { return status >= 500 }
+	}
+	return &CircuitBreaker{name: name, opts: opts, circuits: make(map[string]*circuit)}
+}
+
+// Name returns the name of the circuit breaker.
+func (c *CircuitBreaker) Name() string {
+	return c.name
+}
+
+// Middleware short-circuits requests to the wrapped handler while its circuit is open.
+func (c *CircuitBreaker) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := partitionKey(r, c.opts.PerRoute)
+		circ := c.circuit(key)
+
+		trial, wait, from, to := circ.allow(time.Now(), &c.opts)
+		c.notify(key, from, to)
+		if wait > 0 {
+			w.Header().Set("Retry-After", retryAfter(wait))
+			WriteError(w, http.StatusServiceUnavailable, "circuit open")
+			return
+		}
+
+		ptw := &passThroughResponseWriter{http.StatusOK, w}
+		start := time.Now()
+		failed := true
+		defer func() {
+			from, to := circ.record(time.Now(), failed, time.Since(start), trial, &c.opts)
+			c.notify(key, from, to)
+		}()
+		next.ServeHTTP(ptw, r)
+		failed = c.opts.IsFailure(ptw.StatusCode)
+	})
+}
+
+// Stats returns the state of each circuit, sorted by route.
+func (c *CircuitBreaker) Stats() []CircuitStats {
+	c.mu.Lock()
+	keys := make([]string, 0, len(c.circuits))
+	for key := range c.circuits {
+		keys = append(keys, key)
+	}
+	c.mu.Unlock()
+	sort.Strings(keys)
+
+	now := time.Now()
+	stats := make([]CircuitStats, 0, len(keys))
+	for _, key := range keys {
+		stats = append(stats, c.circuit(key).stats(key, now, &c.opts))
+	}
+	return stats
+}
+
+// Introspect returns the result of Stats.
+func (c *CircuitBreaker) Introspect() interface{} {
+	return c.Stats()
+}
+
+func (c *CircuitBreaker) circuit(key string) *circuit {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	circ, ok := c.circuits[key]
+	if !ok {
+		circ = &circuit{}
+		c.circuits[key] = circ
+	}
+	return circ
+}
+
+func (c *CircuitBreaker) notify(key string, from, to CircuitState) {
+	if from != to && c.opts.Events != nil {
+		c.opts.Events(CircuitStateEvent{Name: c.name, Route: key, From: from, To: to})
+	}
+}
+
+// allow decides whether a request may be handled, returning how long to wait when it may not. Trial requests of a
+// half-open circuit are reported so that their outcome decides the next state. The returned states differ when the
+// circuit changed state.
+func (c *circuit) allow(now time.Time, opts *CircuitBreakerOptions) (trial bool, wait time.Duration, from, to CircuitState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	from = c.state
+
+	if c.state == CircuitOpen {
+		if now.Before(c.openUntil) {
+			c.rejected++
+			return false, c.openUntil.Sub(now), from, c.state
+		}
+		c.state, c.trials, c.successes = CircuitHalfOpen, 0, 0
+	}
+	if c.state == CircuitHalfOpen {
+		if c.trials >= opts.HalfOpenRequests {
+			c.rejected++
+			return false, time.Second, from, c.state
+		}
+		c.trials++
+		return true, 0, from, c.state
+	}
+	return false, 0, from, c.state
+}
+
+// record counts the outcome of a request and trips or closes the circuit as needed.
+func (c *circuit) record(now time.Time, failed bool, elapsed time.Duration, trial bool, opts *CircuitBreakerOptions) (from, to CircuitState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	from = c.state
+	slow := opts.SlowThreshold > 0 && elapsed >= opts.SlowThreshold
+
+	if trial {
+		if c.state != CircuitHalfOpen {
+			return from, c.state
+		}
+		if failed || slow {
+			c.open(now, opts)
+			return from, c.state
+		}
+		if c.successes++; c.successes >= opts.HalfOpenRequests {
+			c.state = CircuitClosed
+			c.buckets = [circuitBuckets]circuitBucket{}
+		}
+		return from, c.state
+	}
+	if c.state != CircuitClosed {
+		return from, c.state
+	}
+
+	b := c.bucket(now, opts)
+	b.requests++
+	if failed {
+		b.failures++
+	}
+	if slow {
+		b.slow++
+	}
+
+	requests, failures, slowCount := c.totals(now, opts)
+	if requests >= opts.MinRequests && (float64(failures)/float64(requests) >= opts.ErrorRate ||
+		(opts.SlowThreshold > 0 && float64(slowCount)/float64(requests) >= opts.SlowRate)) {
+		c.open(now, opts)
+	}
+	return from, c.state
+}
+
+func (c *circuit) open(now time.Time, opts *CircuitBreakerOptions) {
+	c.state = CircuitOpen
+	c.openUntil = now.Add(opts.OpenFor)
+	c.trips++
+}
+
+// bucket returns the bucket for the current time, clearing it if it holds counts from an earlier window.
+func (c *circuit) bucket(now time.Time, opts *CircuitBreakerOptions) *circuitBucket {
+	size := opts.Window / circuitBuckets
+	start := now.Truncate(size)
+	b := &c.buckets[(start.UnixNano()/int64(size))%circuitBuckets]
+	if !b.start.Equal(start) {
+		*b = circuitBucket{start: start}
+	}
+	return b
+}
+
+// totals sums the buckets within the window.
+func (c *circuit) totals(now time.Time, opts *CircuitBreakerOptions) (requests, failures, slow int) {
+	for _, b := range c.buckets {
+		if now.Sub(b.start) < opts.Window {
+			requests += b.requests
+			failures += b.failures
+			slow += b.slow
+		}
+	}
+	return requests, failures, slow
+}
+
+func (c *circuit) stats(key string, now time.Time, opts *CircuitBreakerOptions) CircuitStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	requests, failures, slow := c.totals(now, opts)
+	stats := CircuitStats{key, c.state, requests, failures, slow, c.trips, c.rejected, nil}
+	if c.state == CircuitOpen {
+		until := c.openUntil
+		stats.OpenUntil = &until
+	}
+	return stats
+}
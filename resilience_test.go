@@ -0,0 +1,210 @@
+package xrouter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// eventLog records the events fired by middleware.
+type eventLog struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (l *eventLog) add(evt Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, evt)
+}
+
+func (l *eventLog) list() []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Event(nil), l.events...)
+}
+
+func TestBulkhead(t *testing.T) {
+	events := &eventLog{}
+	b := NewBulkhead("db", BulkheadOptions{MaxConcurrent: 1, MaxQueue: 1, QueueTimeout: 50 * time.Millisecond,
+		Events: events.add})
+	release := make(chan struct{})
+
+	r := New()
+	r.Use(b.Middleware)
+	r.GET("/slow", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		<-release
+	})
+	call := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+		return w
+	}
+	waitFor := func(inFlight int, queued int64) {
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			if stats := b.Stats(); len(stats) == 1 && stats[0].InFlight == inFlight && stats[0].Queued == queued {
+				return
+			}
+		}
+		t.Fatalf("bulkhead did not reach %d in flight and %d queued: %+v", inFlight, queued, b.Stats())
+	}
+
+	done := make(chan *httptest.ResponseRecorder, 2)
+	go func() { done <- call() }()
+	waitFor(1, 0)
+
+	// The queued request times out while the first request holds the only slot.
+	w := call()
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	go func() { done <- call() }()
+	waitFor(1, 1)
+	w = call()
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	close(release)
+	assert.Equal(t, http.StatusOK, (<-done).Code)
+	assert.Equal(t, http.StatusOK, (<-done).Code)
+	assert.Equal(t, []BulkheadStats{{InFlight: 0, Queued: 0, Rejected: 2}}, b.Stats())
+	assert.Equal(t, []Event{
+		BulkheadRejectedEvent{Name: "db", Reason: "queue timeout"},
+		BulkheadRejectedEvent{Name: "db", Reason: "queue full"},
+	}, events.list())
+}
+
+func TestBulkheadPerRoute(t *testing.T) {
+	b := NewBulkhead("api", BulkheadOptions{PerRoute: true})
+	r := New()
+	g := r.Group("/api")
+	g.UseNamed("bulkhead", b.Middleware)
+	g.GET("/a", GetTest)
+	g.GET("/b/:id", GetTest)
+
+	for _, path := range []string{"/api/a", "/api/b/1", "/api/b/2"} {
+		r.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	assert.Equal(t, []BulkheadStats{{Route: "GET /api/a"}, {Route: "GET /api/b/:id"}}, b.Stats())
+}
+
+func TestCircuitBreakerTripsOnErrors(t *testing.T) {
+	events := &eventLog{}
+	cb := NewCircuitBreaker("payments", CircuitBreakerOptions{MinRequests: 4, ErrorRate: 0.5,
+		OpenFor: 50 * time.Millisecond, Events: events.add})
+	var status atomic.Int32
+	status.Store(http.StatusInternalServerError)
+	var calls atomic.Int32
+
+	r := New()
+	r.Use(cb.Middleware)
+	r.GET("/pay", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(int(status.Load()))
+	})
+	call := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/pay", nil))
+		return w
+	}
+
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, http.StatusInternalServerError, call().Code)
+	}
+	w := call()
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Equal(t, int32(4), calls.Load())
+
+	stats := cb.Stats()
+	if assert.Len(t, stats, 1) {
+		assert.Equal(t, CircuitOpen, stats[0].State)
+		assert.Equal(t, uint64(1), stats[0].Trips)
+		assert.Equal(t, uint64(1), stats[0].Rejected)
+		assert.NotNil(t, stats[0].OpenUntil)
+	}
+
+	// After the open period a successful trial request closes the circuit.
+	time.Sleep(60 * time.Millisecond)
+	status.Store(http.StatusOK)
+	assert.Equal(t, http.StatusOK, call().Code)
+	assert.Equal(t, http.StatusOK, call().Code)
+	assert.Equal(t, CircuitClosed, cb.Stats()[0].State)
+
+	assert.Equal(t, []Event{
+		CircuitStateEvent{Name: "payments", From: CircuitClosed, To: CircuitOpen},
+		CircuitStateEvent{Name: "payments", From: CircuitOpen, To: CircuitHalfOpen},
+		CircuitStateEvent{Name: "payments", From: CircuitHalfOpen, To: CircuitClosed},
+	}, events.list())
+}
+
+func TestCircuitBreakerReopensOnFailedTrial(t *testing.T) {
+	cb := NewCircuitBreaker("panics", CircuitBreakerOptions{MinRequests: 1, OpenFor: 20 * time.Millisecond})
+	r := New()
+	r.Use(cb.Middleware)
+	r.GET("/panic", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	call := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/panic", nil))
+		return w
+	}
+
+	assert.Panics(t, func() { call() })
+	assert.Equal(t, http.StatusServiceUnavailable, call().Code)
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Panics(t, func() { call() })
+	assert.Equal(t, CircuitOpen, cb.Stats()[0].State)
+	assert.Equal(t, uint64(2), cb.Stats()[0].Trips)
+}
+
+func TestCircuitBreakerTripsOnLatency(t *testing.T) {
+	cb := NewCircuitBreaker("search", CircuitBreakerOptions{MinRequests: 2, SlowThreshold: 5 * time.Millisecond,
+		PerRoute: true})
+	r := New()
+	r.Use(cb.Middleware)
+	r.GET("/slow", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+	})
+	r.GET("/fast", GetTest)
+
+	for _, path := range []string{"/slow", "/slow", "/fast", "/fast"} {
+		r.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	stats := cb.Stats()
+	if assert.Len(t, stats, 2) {
+		assert.Equal(t, "GET /fast", stats[0].Route)
+		assert.Equal(t, CircuitClosed, stats[0].State)
+		assert.Equal(t, "GET /slow", stats[1].Route)
+		assert.Equal(t, CircuitOpen, stats[1].State)
+		assert.Equal(t, 2, stats[1].Slow)
+	}
+}
+
+func TestIntrospectionHandler(t *testing.T) {
+	b := NewBulkhead("db", BulkheadOptions{})
+	cb := NewCircuitBreaker("payments", CircuitBreakerOptions{})
+	r := New()
+	r.Use(b.Middleware)
+	r.Use(cb.Middleware)
+	r.GET("/test", GetTest)
+	r.GET("/debug/resilience", IntrospectionHandler(b, cb))
+
+	r.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test", nil))
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/debug/resilience", nil))
+
+	var body map[string][]map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, float64(1), body["db"][0]["in_flight"])
+	assert.Equal(t, "closed", body["payments"][0]["state"])
+	assert.Equal(t, float64(1), body["payments"][0]["requests"])
+}
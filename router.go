@@ -3,6 +3,7 @@ package xrouter
 import (
 	"context"
 	"net/http"
+	"net/url"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/alice"
@@ -11,38 +12,75 @@ import (
 // RouterGroup allows for grouping routes with separate middleware.
 type RouterGroup interface {
 
-	// Use adds middleware to the router.
+	// Use adds middleware to the router. Middleware applies to routes in child groups, including groups and routes
+	// created before it was added. It is named after its function, with a numeric suffix for repeated middleware.
 	Use(f func(next http.Handler) http.Handler)
 
-	// Chain returns the middleware chain.
+	// UseNamed adds middleware with a name which can be referenced by UseBefore, UseAfter and Without.
+	UseNamed(name string, f func(next http.Handler) http.Handler)
+
+	// UseBefore inserts named middleware before the middleware with the given name.
+	UseBefore(before, name string, f func(next http.Handler) http.Handler)
+
+	// UseAfter inserts named middleware after the middleware with the given name.
+	UseAfter(after, name string, f func(next http.Handler) http.Handler)
+
+	// Without removes the named middleware, including inherited middleware, from the group and its children.
+	Without(names ...string)
+
+	// Chain returns the effective middleware chain.
 	Chain() alice.Chain
-	
+
 	// Group returns a new router which strips the given path before the request is handled. All middleware is transferred to the child group.
 	Group(path string) RouterGroup
 
 	// Path returns the root path of the RouterGroup
 	Path() string
 
+	// Handle adds a handler for the given method and path. Options can attach additional route information.
+	Handle(method, path string, handler Route, opts ...RouteOption)
+
 	// GET adds a GET handler at the given path.
-	GET(path string, handler Route)
+	GET(path string, handler Route, opts ...RouteOption)
 
 	// POST adds a POST handler at the given path.
-	POST(path string, handler Route)
+	POST(path string, handler Route, opts ...RouteOption)
 
 	// PUT adds a PUT handler at the given path.
-	PUT(path string, handler Route)
+	PUT(path string, handler Route, opts ...RouteOption)
 
 	// OPTIONS adds a OPTIONS handler at the given path.
-	OPTIONS(path string, handler Route)
+	OPTIONS(path string, handler Route, opts ...RouteOption)
 
 	// HEAD adds a HEAD handler at the given path.
-	HEAD(path string, handler Route)
+	HEAD(path string, handler Route, opts ...RouteOption)
 
 	// PATCH adds a PATCH handler at the given path.
-	PATCH(path string, handler Route)
+	PATCH(path string, handler Route, opts ...RouteOption)
 
 	// DELETE adds a DELETE handler at the given path.
-	DELETE(path string, handler Route)
+	DELETE(path string, handler Route, opts ...RouteOption)
+
+	// WebSocket adds a GET handler at the given path which upgrades requests to WebSocket connections. Middleware runs
+	// before the upgrade.
+	WebSocket(path string, handler WebSocketHandler, opts ...RouteOption)
+
+	// Proxy forwards every request under the given path to the target, after running the group's middleware.
+	Proxy(path string, target *url.URL, opts ProxyOptions)
+
+	// NotFound adds a handler for routes that don't exist under the group's path. The group with the longest matching
+	// path handles each request.
+	NotFound(http.Handler)
+
+	// MethodNotAllowed handles requests under the group's path in which the route exists but the wrong method was used.
+	MethodNotAllowed(http.Handler)
+
+	// Defaults adds route options, such as metadata or tags, which apply to every route later registered with the
+	// group or its child groups. Routes registered before are unchanged.
+	Defaults(opts ...RouteOption)
+
+	// ShuttingDown returns true once the router serving the group has begun a graceful shutdown.
+	ShuttingDown() bool
 }
 
 // Router defines a root router for handling requests.
@@ -55,17 +93,35 @@ type Router interface {
 	// StaticFiles adds a directory of static content to a specific path.
 	StaticFiles(path string, fs http.Handler)
 
-	// NotFound adds a handler for routes that don't exist.
-	NotFound(http.Handler)
-
-	// MethodNotAllowed handles requests in which the route exists but hte wrong method was used.
-	MethodNotAllowed(http.Handler)
-
 	// Handler returns an http.Handler
 	Handler() http.Handler
 
 	// EventHandler calls the given function for each handler as it is added to the router.
 	EventHandler(func(evt Event))
+
+	// Routes returns the routes which have been registered with the router.
+	Routes() []RouteInfo
+
+	// ServeOpenAPI serves an OpenAPI document describing the registered routes at the given path. Paths ending in .yaml or .yml are served as YAML.
+	ServeOpenAPI(path string, opts OpenAPIOptions)
+
+	// Lookup returns the route which handles the given method and path along with the URL parameters it would receive.
+	Lookup(method, path string) (RouteInfo, httprouter.Params, bool)
+
+	// Validate returns the route registration errors collected by a router created with the CollectErrors option.
+	Validate() error
+
+	// Serve runs an HTTP server for the router until the context is cancelled or a shutdown signal is received.
+	Serve(ctx context.Context, opts ServerOptions) error
+
+	// OnStart adds a hook which is run before the server starts accepting requests.
+	OnStart(h Hook)
+
+	// OnShutdown adds a hook which is run after the server has drained during a graceful shutdown.
+	OnShutdown(h Hook)
+
+	// DeprecatedCalls returns the number of calls to each deprecated route, by caller.
+	DeprecatedCalls() []DeprecatedCalls
 }
 
 // Route is a function with exposes the request context as an argument. For Go 1.7+, the request has an attached context.
@@ -73,10 +129,13 @@ type Router interface {
 type Route func(context.Context, http.ResponseWriter, *http.Request)
 
 // New creates a router which wraps an httprouter.
-func New() Router {
-	c := alice.New()
+func New(opts ...Option) Router {
 	r := httprouter.New()
-	return &router{r, newGroup("", c, r, evtHandler)}
+	rt := &router{router: r, group: newGroup("", nil, r, &routeTable{deprecations: newDeprecationTracker()}, evtHandler)}
+	for _, opt := range opts {
+		opt(rt)
+	}
+	return rt
 }
 
 // Default event handler
@@ -84,8 +143,12 @@ func evtHandler(Event) {}
 
 // Router is a simple abstraction on top of httprouter which allows for simpler use of the http.Handler interface from the standard library.
 type router struct {
-	router *httprouter.Router
-	group  *routerGroup
+	router    *httprouter.Router
+	group     *routerGroup
+	normalize *PathNormalization
+
+	startHooks    []Hook
+	shutdownHooks []Hook
 }
 
 // Use adds middleware to the router.
@@ -93,6 +156,26 @@ func (r *router) Use(f func(next http.Handler) http.Handler) {
 	r.group.Use(f)
 }
 
+// UseNamed adds named middleware to the router.
+func (r *router) UseNamed(name string, f func(next http.Handler) http.Handler) {
+	r.group.UseNamed(name, f)
+}
+
+// UseBefore inserts named middleware before the middleware with the given name.
+func (r *router) UseBefore(before, name string, f func(next http.Handler) http.Handler) {
+	r.group.UseBefore(before, name, f)
+}
+
+// UseAfter inserts named middleware after the middleware with the given name.
+func (r *router) UseAfter(after, name string, f func(next http.Handler) http.Handler) {
+	r.group.UseAfter(after, name, f)
+}
+
+// Without removes the named middleware from the router.
+func (r *router) Without(names ...string) {
+	r.group.Without(names...)
+}
+
 // Chain returns the middleware chain.
 func (r *router) Chain() alice.Chain {
 	return r.group.Chain()
@@ -100,54 +183,68 @@ func (r *router) Chain() alice.Chain {
 
 // NotFound adds a handler for unknown routes.
 func (r *router) NotFound(h http.Handler) {
-	r.router.NotFound = r.group.chain.Then(h)
-	r.group.evtHandler(NotFoundHandlerEvent{})
+	r.group.NotFound(h)
 }
 
 // MethodNotAllowed adds a handler for existing routes and unknown methods.
 func (r *router) MethodNotAllowed(h http.Handler) {
-	r.router.MethodNotAllowed = r.group.chain.Then(h)
-	r.group.evtHandler(MethodNotAllowedHandlerEvent{})
+	r.group.MethodNotAllowed(h)
+}
+
+// Handle adds a handler for the given method and path.
+func (r *router) Handle(method, path string, handler Route, opts ...RouteOption) {
+	r.group.Handle(method, path, handler, opts...)
 }
 
 // GET adds a GET handler at the given path.
-func (r *router) GET(path string, handler Route) {
-	r.group.GET(path, handler)
+func (r *router) GET(path string, handler Route, opts ...RouteOption) {
+	r.group.GET(path, handler, opts...)
 }
 
 // POST adds a POST handler at the given path.
-func (r *router) POST(path string, handler Route) {
-	r.group.POST(path, handler)
+func (r *router) POST(path string, handler Route, opts ...RouteOption) {
+	r.group.POST(path, handler, opts...)
 }
 
 // PUT adds a PUT handler at the given path.
-func (r *router) PUT(path string, handler Route) {
-	r.group.PUT(path, handler)
+func (r *router) PUT(path string, handler Route, opts ...RouteOption) {
+	r.group.PUT(path, handler, opts...)
 }
 
 // OPTIONS adds a OPTIONS handler at the given path.
-func (r *router) OPTIONS(path string, handler Route) {
-	r.group.OPTIONS(path, handler)
+func (r *router) OPTIONS(path string, handler Route, opts ...RouteOption) {
+	r.group.OPTIONS(path, handler, opts...)
 }
 
 // HEAD adds a HEAD handler at the given path.
-func (r *router) HEAD(path string, handler Route) {
-	r.group.HEAD(path, handler)
+func (r *router) HEAD(path string, handler Route, opts ...RouteOption) {
+	r.group.HEAD(path, handler, opts...)
 }
 
 // PATCH adds a PATCH handler at the given path.
-func (r *router) PATCH(path string, handler Route) {
-	r.group.PATCH(path, handler)
+func (r *router) PATCH(path string, handler Route, opts ...RouteOption) {
+	r.group.PATCH(path, handler, opts...)
 }
 
 // DELETE adds a DELETE handler at the given path.
-func (r *router) DELETE(path string, handler Route) {
-	r.group.DELETE(path, handler)
+func (r *router) DELETE(path string, handler Route, opts ...RouteOption) {
+	r.group.DELETE(path, handler, opts...)
+}
+
+// WebSocket adds a WebSocket handler at the given path.
+func (r *router) WebSocket(path string, handler WebSocketHandler, opts ...RouteOption) {
+	r.group.WebSocket(path, handler, opts...)
+}
+
+// Proxy adds a reverse proxy to the target at the given path.
+func (r *router) Proxy(path string, target *url.URL, opts ProxyOptions) {
+	r.group.Proxy(path, target, opts)
 }
 
 // StaticRoot adds a directory of static content to serve at root. All requests not matched to a route will be handled here. It is an alias to the NotFound method.
 func (r *router) StaticRoot(fs http.Handler) {
-	r.router.NotFound = r.group.chain.Then(fs)
+	r.group.table.fallbacks.notFound = setScoped(r.group.table.fallbacks.notFound, "", r.group.live(fs))
+	r.router.NotFound = http.HandlerFunc(r.group.table.fallbacks.dispatchNotFound)
 }
 
 // StaticFiles adds a directory of static content to a specific path.
@@ -160,9 +257,17 @@ func (r *router) StaticFiles(path string, fs http.Handler) {
 
 // Handler returns an http.Handler
 func (r *router) Handler() http.Handler {
+	if r.normalize != nil {
+		return normalizeHandler(*r.normalize, r.router)
+	}
 	return r.router
 }
 
+// Defaults adds route options which apply to every route later registered with the router.
+func (r *router) Defaults(opts ...RouteOption) {
+	r.group.Defaults(opts...)
+}
+
 // Group returns a new router which strips the given path before the request is handled. All the middleware from the router is transferred.
 func (r *router) Group(path string) RouterGroup {
 	return r.group.Group(path)
@@ -176,3 +281,24 @@ func (r *router) EventHandler(h func(Event)) {
 func (r *router) Path() string {
 	return "/"
 }
+
+// Routes returns the routes which have been registered with the router.
+func (r *router) Routes() []RouteInfo {
+	return r.group.table.list()
+}
+
+// ServeOpenAPI serves an OpenAPI document describing the registered routes at the given path. The document route is
+// registered like any other route, behind the router's middleware, but is not included in the document.
+func (r *router) ServeOpenAPI(path string, opts OpenAPIOptions) {
+	r.Handle("GET", path, openAPIHandler(r, path, opts), Undocumented(), Summary("OpenAPI document"))
+}
+
+// Lookup returns the route which handles the given method and path along with the URL parameters it would receive.
+func (r *router) Lookup(method, path string) (RouteInfo, httprouter.Params, bool) {
+	if h, params, _ := r.router.Lookup(method, path); h != nil {
+		if info, ok := r.group.table.match(method, path, params); ok && matchConstraints(info.Constraints, params) {
+			return info, params, true
+		}
+	}
+	return RouteInfo{}, nil, false
+}
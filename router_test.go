@@ -1,6 +1,7 @@
 package xrouter
 
 import (
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -69,6 +70,23 @@ func PatchTest(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// send serves a request through the router's handler and returns the recorded response. Headers are given as
+// name, value pairs.
+func send(r Router, method, target string, body io.Reader, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, body)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	return sendRequest(r, req)
+}
+
+// sendRequest serves a prepared request through the router's handler and returns the recorded response.
+func sendRequest(r Router, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	return w
+}
+
 // SetupTest creates the HTTP server for test.
 func (suite *RouterTestSuite) SetupSuite() {
 	suite.router = New()
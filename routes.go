@@ -0,0 +1,194 @@
+package xrouter
+
+import (
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// RouteInfo describes a route which has been registered with the router.
+type RouteInfo struct {
+	Method string
+	Path   string
+
+	// Group is the prefix of the group which registered the route and Source is the file:line of the registration call.
+	Group  string
+	Source string
+
+	// Summary, Description and Tags document the route.
+	Summary     string
+	Description string
+	Tags        []string
+
+	// Security lists the names of the security schemes which protect the route.
+	Security []string
+
+	// Silent routes are excluded from access logs and metrics.
+	Silent bool
+
+	// Undocumented routes are excluded from OpenAPI documents.
+	Undocumented bool
+
+	// CachePolicy overrides the policy of the Cache middleware for the route.
+	CachePolicy *CachePolicy
+
+	// Middleware lists the names of the route's effective middleware, outermost first. Middleware added with Use is
+	// named after the function which implements it.
+	Middleware []string
+
+	// Metadata holds arbitrary annotations such as the owning team or stability level.
+	Metadata map[string]string
+
+	// Constraints maps constrained path parameters to their constraints, such as "int" or a regular expression.
+	Constraints map[string]string
+
+	// Versions lists the API versions served by routes registered with a VersionedAPI.
+	Versions []string
+
+	// Deprecation is set for deprecated routes.
+	Deprecation *Deprecation
+
+	// WebSocket holds the options of WebSocket routes and is nil for other routes.
+	WebSocket *WebSocketOptions
+
+	// Upstreams lists the targets of proxy routes.
+	Upstreams []string
+
+	// Priority decides which requests the LoadShedder rejects first.
+	Priority Priority
+
+	// CSRFExempt routes are not checked by the CSRF middleware.
+	CSRFExempt bool
+
+	// Request and Response are the Go types of the decoded request body and the encoded response body, if known.
+	Request  reflect.Type
+	Response reflect.Type
+}
+
+// RouteOption modifies the information stored for a route as it is registered.
+type RouteOption func(*RouteInfo)
+
+// Schema attaches request and response types to a route. A nil type means the body is unknown or empty.
+func Schema(request, response reflect.Type) RouteOption {
+	return func(info *RouteInfo) {
+		info.Request = request
+		info.Response = response
+	}
+}
+
+// Summary sets a short summary of the route.
+func Summary(summary string) RouteOption {
+	return func(info *RouteInfo) {
+		info.Summary = summary
+	}
+}
+
+// Description sets a long description of the route.
+func Description(description string) RouteOption {
+	return func(info *RouteInfo) {
+		info.Description = description
+	}
+}
+
+// Tags adds tags to the route.
+func Tags(tags ...string) RouteOption {
+	return func(info *RouteInfo) {
+		info.Tags = append(info.Tags, tags...)
+	}
+}
+
+// Security adds the names of security schemes which protect the route.
+func Security(schemes ...string) RouteOption {
+	return func(info *RouteInfo) {
+		info.Security = append(info.Security, schemes...)
+	}
+}
+
+// Meta sets a metadata annotation on the route, replacing any value inherited from its group.
+func Meta(key, value string) RouteOption {
+	return func(info *RouteInfo) {
+		if info.Metadata == nil {
+			info.Metadata = make(map[string]string)
+		}
+		info.Metadata[key] = value
+	}
+}
+
+// Silent excludes the route from access logs and metrics. It is intended for health checks and other infrastructure routes.
+func Silent() RouteOption {
+	return func(info *RouteInfo) {
+		info.Silent = true
+	}
+}
+
+// Undocumented excludes the route from OpenAPI documents, such as the route serving the document itself.
+func Undocumented() RouteOption {
+	return func(info *RouteInfo) {
+		info.Undocumented = true
+	}
+}
+
+// routeTable holds the routes which have been registered across all groups of a router.
+type routeTable struct {
+	routes    []RouteInfo
+	groups    []*routerGroup
+	errors    []*RouteError
+	collect   bool
+	fallbacks fallbacks
+
+	deprecations *deprecationTracker
+
+	// generation is incremented whenever middleware changes in any group.
+	generation atomic.Uint64
+
+	// shuttingDown is set once the router's server begins a graceful shutdown.
+	shuttingDown atomic.Bool
+}
+
+func (t *routeTable) add(info RouteInfo, group *routerGroup) {
+	t.routes = append(t.routes, info)
+	t.groups = append(t.groups, group)
+}
+
+// route returns a registered route with its current effective middleware.
+func (t *routeTable) route(i int) RouteInfo {
+	info := t.routes[i]
+	info.Middleware = t.groups[i].middlewareNames()
+	return info
+}
+
+func (t *routeTable) list() []RouteInfo {
+	routes := make([]RouteInfo, len(t.routes))
+	for i := range t.routes {
+		routes[i] = t.route(i)
+	}
+	return routes
+}
+
+// match finds the registered route which httprouter matched for the path. Since httprouter does not allow ambiguous
+// routes, the only route whose pattern expands to the path using the matched params is the route which was selected.
+func (t *routeTable) match(method, path string, params httprouter.Params) (RouteInfo, bool) {
+	for i, info := range t.routes {
+		if info.Method == method && expandPath(info.Path, params) == path {
+			return t.route(i), true
+		}
+	}
+	return RouteInfo{}, false
+}
+
+// expandPath replaces the wildcards in an httprouter pattern with the given param values.
+func expandPath(pattern string, params httprouter.Params) string {
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			segments[i] = params.ByName(segment[1:])
+		case strings.HasPrefix(segment, "*"):
+			// Catch-all values include the leading slash.
+			return strings.Join(segments[:i], "/") + params.ByName(segment[1:])
+		}
+	}
+	return strings.Join(segments, "/")
+}
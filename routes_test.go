@@ -0,0 +1,71 @@
+package xrouter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookup(t *testing.T) {
+	r := New()
+	api := r.Group("/api/v1")
+	api.GET("/apps/:app/users/:userid", GetTest)
+	api.GET("/apps/:app/settings", GetTest)
+	r.GET("/files/*filepath", GetTest)
+
+	info, params, ok := r.Lookup("GET", "/api/v1/apps/1/users/2")
+	assert.True(t, ok)
+	assert.Equal(t, "/api/v1/apps/:app/users/:userid", info.Path)
+	assert.Equal(t, "/api/v1", info.Group)
+	assert.Equal(t, "1", params.ByName("app"))
+	assert.Equal(t, "2", params.ByName("userid"))
+
+	info, _, ok = r.Lookup("GET", "/api/v1/apps/1/settings")
+	assert.True(t, ok)
+	assert.Equal(t, "/api/v1/apps/:app/settings", info.Path)
+
+	info, params, ok = r.Lookup("GET", "/files/css/site.css")
+	assert.True(t, ok)
+	assert.Equal(t, "/files/*filepath", info.Path)
+	assert.Equal(t, "/css/site.css", params.ByName("filepath"))
+
+	_, _, ok = r.Lookup("POST", "/api/v1/apps/1/settings")
+	assert.False(t, ok)
+
+	_, _, ok = r.Lookup("GET", "/missing")
+	assert.False(t, ok)
+}
+
+func TestGroupMetadata(t *testing.T) {
+	r := New()
+	r.Defaults(Meta("owner", "platform"), Tags("api"))
+
+	billing := r.Group("/billing")
+	billing.Defaults(Meta("owner", "billing"), Meta("stability", "beta"), Tags("billing"))
+	billing.GET("/invoices", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(MetaFromContext(ctx, "owner") + " " + MetaFromContext(ctx, "slo")))
+	}, Meta("slo", "99.9"), Meta("stability", "stable"))
+
+	// Defaults added after a group is created do not affect it.
+	r.Defaults(Meta("tier", "1"))
+	r.GET("/status", GetTest)
+
+	info, _, ok := r.Lookup("GET", "/billing/invoices")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"owner": "billing", "stability": "stable", "slo": "99.9"}, info.Metadata)
+	assert.Equal(t, []string{"api", "billing"}, info.Tags)
+
+	info, _, _ = r.Lookup("GET", "/status")
+	assert.Equal(t, map[string]string{"owner": "platform", "tier": "1"}, info.Metadata)
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/billing/invoices", nil))
+	assert.Equal(t, "billing 99.9", w.Body.String())
+
+	doc := GenerateOpenAPI(OpenAPIOptions{}, r.Routes())
+	op := doc["paths"].(map[string]interface{})["/billing/invoices"].(map[string]interface{})["get"].(map[string]interface{})
+	assert.Equal(t, "billing", op["x-metadata"].(map[string]string)["owner"])
+}
@@ -0,0 +1,183 @@
+package xrouter
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Hook is a function which is run as the server starts or shuts down.
+type Hook func(ctx context.Context) error
+
+// ServerOptions configures the http.Server created by Router.Serve. Zero values use the defaults listed for each field.
+type ServerOptions struct {
+	// Addr is the address to listen on. Addresses prefixed with "unix:" listen on a Unix socket. Defaults to ":8080".
+	Addr string
+
+	// Listener is used instead of listening on Addr if it is set.
+	Listener net.Listener
+
+	// ReadHeaderTimeout defaults to 10 seconds, ReadTimeout to 30 seconds and IdleTimeout to 2 minutes. WriteTimeout
+	// defaults to none, since it would end server-sent event streams and WebSocket connections; handlers which need one
+	// can set a deadline with http.ResponseController.
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+
+	// ShutdownTimeout is how long in-flight requests are given to complete during shutdown. The shutdown hooks are
+	// then given the same time to run. Defaults to 30 seconds.
+	ShutdownTimeout time.Duration
+
+	// ShutdownDelay is how long the server keeps serving after shutdown begins, so that load balancers can observe
+	// failing readiness checks before the listener is closed. Defaults to no delay.
+	ShutdownDelay time.Duration
+
+	// Signals which trigger a graceful shutdown. Defaults to SIGINT and SIGTERM.
+	Signals []os.Signal
+}
+
+func (o *ServerOptions) setDefaults() {
+	if o.Addr == "" {
+		o.Addr = ":8080"
+	}
+	if o.ReadHeaderTimeout == 0 {
+		o.ReadHeaderTimeout = 10 * time.Second
+	}
+	if o.ReadTimeout == 0 {
+		o.ReadTimeout = 30 * time.Second
+	}
+	if o.IdleTimeout == 0 {
+		o.IdleTimeout = 2 * time.Minute
+	}
+	if o.ShutdownTimeout == 0 {
+		o.ShutdownTimeout = 30 * time.Second
+	}
+	if len(o.Signals) == 0 {
+		o.Signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+}
+
+// listen opens a TCP or Unix socket listener for the address. Stale Unix socket files are removed first, but other
+// files at the path are left alone and make listening fail.
+func listen(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			if err := os.Remove(path); err != nil {
+				return nil, err
+			}
+		}
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// OnStart adds a hook which is run, in order of registration, before the server starts accepting requests.
+func (r *router) OnStart(h Hook) {
+	r.startHooks = append(r.startHooks, h)
+}
+
+// OnShutdown adds a hook which is run, in order of registration, after in-flight requests have drained.
+func (r *router) OnShutdown(h Hook) {
+	r.shutdownHooks = append(r.shutdownHooks, h)
+}
+
+// ShuttingDown returns true once a graceful shutdown has begun.
+func (r *router) ShuttingDown() bool {
+	return r.group.ShuttingDown()
+}
+
+// ShutdownNotify returns a channel which is closed when the server handling the request begins a graceful shutdown.
+// Long-lived responses, such as WebSocket connections and event streams, should end when it is closed since the server
+// does not wait for them. It returns nil for requests which are not served by Router.Serve.
+func ShutdownNotify(ctx context.Context) <-chan struct{} {
+	ch, _ := ctx.Value(shutdownKey).(<-chan struct{})
+	return ch
+}
+
+// Serve runs an http.Server for the router until the context is cancelled or a shutdown signal is received, then
+// shuts down gracefully. It returns nil after a clean shutdown.
+func (r *router) Serve(ctx context.Context, opts ServerOptions) error {
+	opts.setDefaults()
+	ctx, stop := signal.NotifyContext(ctx, opts.Signals...)
+	defer stop()
+
+	ln := opts.Listener
+	if ln == nil {
+		var err error
+		if ln, err = listen(opts.Addr); err != nil {
+			return err
+		}
+	}
+
+	for _, hook := range r.startHooks {
+		if err := hook(ctx); err != nil {
+			ln.Close()
+			return err
+		}
+	}
+
+	draining := make(chan struct{})
+	srv := &http.Server{
+		Handler:           r.Handler(),
+		ReadHeaderTimeout: opts.ReadHeaderTimeout,
+		ReadTimeout:       opts.ReadTimeout,
+		WriteTimeout:      opts.WriteTimeout,
+		IdleTimeout:       opts.IdleTimeout,
+		BaseContext: func(net.Listener) context.Context {
+			return context.WithValue(context.Background(), shutdownKey, (<-chan struct{})(draining))
+		},
+	}
+	// Shutdown does not track hijacked connections or wait for streams, so they are told to close instead.
+	srv.RegisterOnShutdown(func() { close(draining) })
+	r.group.table.shuttingDown.Store(false)
+	r.group.evtHandler(ServerStartEvent{ln.Addr().Network(), ln.Addr().String()})
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errc:
+		r.group.evtHandler(ServerStopEvent{err})
+		return err
+	case <-ctx.Done():
+	}
+
+	r.group.table.shuttingDown.Store(true)
+	r.group.evtHandler(ServerShutdownEvent{})
+	if opts.ShutdownDelay > 0 {
+		time.Sleep(opts.ShutdownDelay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), opts.ShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		errs = append(errs, err)
+	}
+	if err := <-errc; err != nil && err != http.ErrServerClosed {
+		errs = append(errs, err)
+	}
+	// The hooks get their own deadline, since draining may have used up the shutdown context.
+	hookCtx, cancelHooks := context.WithTimeout(context.Background(), opts.ShutdownTimeout)
+	defer cancelHooks()
+	for _, hook := range r.shutdownHooks {
+		if err := hook(hookCtx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	err := errors.Join(errs...)
+	r.group.evtHandler(ServerStopEvent{err})
+	return err
+}
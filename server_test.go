@@ -0,0 +1,214 @@
+package xrouter
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServe(t *testing.T) {
+	var order []string
+	var events []Event
+
+	r := New()
+	r.GET("/", GetTest)
+	r.EventHandler(func(evt Event) {
+		events = append(events, evt)
+	})
+	r.OnStart(func(ctx context.Context) error {
+		order = append(order, "start1")
+		return nil
+	})
+	r.OnStart(func(ctx context.Context) error {
+		order = append(order, "start2")
+		return nil
+	})
+	r.OnShutdown(func(ctx context.Context) error {
+		order = append(order, "shutdown1")
+		return nil
+	})
+	r.OnShutdown(func(ctx context.Context) error {
+		order = append(order, "shutdown2")
+		return nil
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- r.Serve(ctx, ServerOptions{Listener: ln, ShutdownTimeout: time.Second})
+	}()
+
+	res, err := http.Get("http://" + ln.Addr().String())
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, ResponseBody, string(body))
+	assert.False(t, r.ShuttingDown())
+	assert.False(t, r.Group("/api").ShuttingDown())
+
+	cancel()
+	assert.NoError(t, <-done)
+	assert.True(t, r.ShuttingDown())
+	assert.True(t, r.Group("/api").ShuttingDown())
+	assert.Equal(t, []string{"start1", "start2", "shutdown1", "shutdown2"}, order)
+
+	assert.Equal(t, 3, len(events))
+	assert.Equal(t, ServerStartEvent{"tcp", ln.Addr().String()}, events[0])
+	assert.Equal(t, ServerShutdownEvent{}, events[1])
+	assert.Equal(t, ServerStopEvent{}, events[2])
+}
+
+func TestServeUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xrouter")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "xrouter.sock")
+
+	r := New()
+	r.GET("/", GetTest)
+
+	started := make(chan struct{})
+	r.OnStart(func(ctx context.Context) error {
+		close(started)
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- r.Serve(ctx, ServerOptions{Addr: "unix:" + sock})
+	}()
+	<-started
+
+	client := http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return net.Dial("unix", sock)
+		},
+	}}
+	res, err := client.Get("http://unix/")
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestListenUnixPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xrouter")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// A stale socket left by a previous server is replaced.
+	sock := filepath.Join(dir, "xrouter.sock")
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: sock, Net: "unix"})
+	assert.NoError(t, err)
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+	ln, err := listen("unix:" + sock)
+	if assert.NoError(t, err) {
+		ln.Close()
+	}
+
+	// Any other file is kept.
+	file := filepath.Join(dir, "config.yaml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("addr: unix:config.yaml"), 0600))
+	_, err = listen("unix:" + file)
+	assert.Error(t, err)
+	data, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, "addr: unix:config.yaml", string(data))
+}
+
+func TestServeStartHookError(t *testing.T) {
+	r := New()
+	r.OnStart(func(ctx context.Context) error {
+		return errors.New("database unavailable")
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	err = r.Serve(context.Background(), ServerOptions{Listener: ln})
+	assert.EqualError(t, err, "database unavailable")
+}
+
+func TestServerOptionsDefaults(t *testing.T) {
+	opts := ServerOptions{}
+	opts.setDefaults()
+	assert.Equal(t, 10*time.Second, opts.ReadHeaderTimeout)
+	assert.Equal(t, 30*time.Second, opts.ReadTimeout)
+	assert.Equal(t, 2*time.Minute, opts.IdleTimeout)
+
+	// Streaming responses must not be cut off by a write deadline.
+	assert.Zero(t, opts.WriteTimeout)
+}
+
+func TestServeShutdownHooksGetFreshContext(t *testing.T) {
+	r := New()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	r.GET("/slow", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	var hookErr error
+	r.OnShutdown(func(ctx context.Context) error {
+		hookErr = ctx.Err()
+		return nil
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- r.Serve(ctx, ServerOptions{Listener: ln, ShutdownTimeout: 20 * time.Millisecond})
+	}()
+	go http.Get("http://" + ln.Addr().String() + "/slow")
+	<-started
+
+	// Draining times out, but the hooks still have time to run.
+	cancel()
+	assert.Error(t, <-done)
+	assert.NoError(t, hookErr)
+	close(release)
+}
+
+func TestServeShutdownClosesWebSockets(t *testing.T) {
+	r := New()
+	r.WebSocket("/ws", func(ctx context.Context, conn *Conn) {
+		conn.ReadMessage()
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- r.Serve(ctx, ServerOptions{Listener: ln, ShutdownTimeout: time.Second})
+	}()
+
+	c, resp := dialWebSocket(t, &httptest.Server{Listener: ln, URL: "http://" + ln.Addr().String()}, "/ws", nil)
+	defer c.conn.Close()
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	cancel()
+	op, payload, err := c.readFrame()
+	assert.NoError(t, err)
+	assert.Equal(t, byte(opClose), op)
+	assert.Equal(t, CloseGoingAway, int(binary.BigEndian.Uint16(payload)))
+	assert.NoError(t, <-done)
+}
@@ -0,0 +1,213 @@
+// Package sse implements Server-Sent Events for xrouter routes.
+//
+//	func events(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+//		stream, err := sse.Stream(ctx, w, r, sse.Heartbeat(15*time.Second))
+//		if err != nil {
+//			http.Error(w, err.Error(), http.StatusInternalServerError)
+//			return
+//		}
+//		defer stream.Close()
+//
+//		for msg := range messages(stream.LastEventID()) {
+//			if err := stream.Send("message", msg.ID, msg.Body); err != nil {
+//				return
+//			}
+//		}
+//	}
+package sse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eliquious/xrouter"
+)
+
+// ErrClosed is returned when sending on a stream whose context has been cancelled or which has been closed.
+var ErrClosed = errors.New("sse: stream closed")
+
+// Option configures a stream.
+type Option func(*EventStream)
+
+// Heartbeat sends a comment line at the given interval so that proxies do not close idle connections.
+func Heartbeat(interval time.Duration) Option {
+	return func(s *EventStream) {
+		s.heartbeat = interval
+	}
+}
+
+// Retry tells the client how long to wait before reconnecting after the connection is lost.
+func Retry(d time.Duration) Option {
+	return func(s *EventStream) {
+		s.retry = d
+	}
+}
+
+// EventStream writes events to a client. It is safe for concurrent use.
+type EventStream struct {
+	w           http.ResponseWriter
+	rc          *http.ResponseController
+	ctx         context.Context
+	cancel      context.CancelFunc
+	lastEventID string
+	heartbeat   time.Duration
+	retry       time.Duration
+
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// Stream starts an event stream response. The stream ends when the context is cancelled or Close is called, which
+// must happen before the handler returns. It fails without writing a response if the response writer does not support
+// flushing. Any write deadline of the server is cleared, since the stream is expected to stay open, and the stream ends
+// when the server begins a graceful shutdown.
+func Stream(ctx context.Context, w http.ResponseWriter, r *http.Request, opts ...Option) (*EventStream, error) {
+	if !canFlush(w) {
+		return nil, fmt.Errorf("sse: streaming unsupported: %w", http.ErrNotSupported)
+	}
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return nil, fmt.Errorf("sse: clearing write deadline: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s := &EventStream{
+		w:           w,
+		rc:          rc,
+		ctx:         ctx,
+		cancel:      cancel,
+		lastEventID: r.Header.Get("Last-Event-ID"),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if s.retry > 0 {
+		fmt.Fprintf(w, "retry: %d\n\n", s.retry/time.Millisecond)
+	}
+	if err := s.rc.Flush(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("sse: streaming unsupported: %w", err)
+	}
+
+	if s.heartbeat > 0 {
+		s.wg.Add(1)
+		go s.heartbeats()
+	}
+	if shutdown := xrouter.ShutdownNotify(ctx); shutdown != nil {
+		go func() {
+			select {
+			case <-shutdown:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+	return s, nil
+}
+
+// canFlush reports whether a response writer, or one it wraps, implements http.Flusher.
+func canFlush(w http.ResponseWriter) bool {
+	for {
+		switch t := w.(type) {
+		case http.Flusher:
+			return true
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = t.Unwrap()
+		default:
+			return false
+		}
+	}
+}
+
+// LastEventID returns the ID of the last event received by the client before it reconnected, if any.
+func (s *EventStream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Done is closed when the stream ends.
+func (s *EventStream) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Send writes an event. The event name and ID are optional. Multi-line data is sent as multiple data lines.
+func (s *EventStream) Send(event, id, data string) error {
+	var b strings.Builder
+	if event != "" {
+		b.WriteString("event: " + stripNewlines(event) + "\n")
+	}
+	if id != "" {
+		b.WriteString("id: " + stripNewlines(id) + "\n")
+	}
+	// Clients end lines at CRLF, LF or a lone CR, so each becomes a separate data line.
+	data = strings.ReplaceAll(strings.ReplaceAll(data, "\r\n", "\n"), "\r", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+	return s.write(b.String())
+}
+
+// Comment writes a comment line, which clients ignore.
+func (s *EventStream) Comment(text string) error {
+	return s.write(": " + stripNewlines(text) + "\n\n")
+}
+
+// SetRetry changes the reconnection delay used by the client.
+func (s *EventStream) SetRetry(d time.Duration) error {
+	return s.write("retry: " + strconv.FormatInt(int64(d/time.Millisecond), 10) + "\n\n")
+}
+
+// Close ends the stream and waits for the heartbeat to stop. Nothing is written after Close returns.
+func (s *EventStream) Close() {
+	s.cancel()
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *EventStream) write(msg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || s.ctx.Err() != nil {
+		return ErrClosed
+	}
+	if _, err := s.w.Write([]byte(msg)); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+func (s *EventStream) heartbeats() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			if s.Comment("heartbeat") != nil {
+				return
+			}
+		}
+	}
+}
+
+func stripNewlines(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
@@ -0,0 +1,162 @@
+package sse
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eliquious/xrouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func readEvent(t *testing.T, r *bufio.Reader) []string {
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		if !assert.NoError(t, err) {
+			return lines
+		}
+		line = strings.TrimRight(line, "\n")
+		if line == "" {
+			return lines
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestStream(t *testing.T) {
+	closed := make(chan struct{})
+	r := xrouter.New()
+	r.Use(xrouter.LogHandler())
+	r.GET("/events", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		defer close(closed)
+		stream, err := Stream(ctx, w, r, Retry(time.Second), Heartbeat(10*time.Millisecond))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer stream.Close()
+
+		start, _ := strconv.Atoi(stream.LastEventID())
+		for i := start + 1; i <= start+2; i++ {
+			stream.Send("count", strconv.Itoa(i), "line one\nline two")
+		}
+		<-stream.Done()
+	})
+	server := httptest.NewServer(r.Handler())
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/events", nil)
+	req.Header.Set("Last-Event-ID", "5")
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	body := bufio.NewReader(res.Body)
+	assert.Equal(t, []string{"retry: 1000"}, readEvent(t, body))
+	assert.Equal(t, []string{"event: count", "id: 6", "data: line one", "data: line two"}, readEvent(t, body))
+	assert.Equal(t, []string{"event: count", "id: 7", "data: line one", "data: line two"}, readEvent(t, body))
+	assert.Equal(t, []string{": heartbeat"}, readEvent(t, body))
+
+	cancel()
+	res.Body.Close()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("handler did not return after the client disconnected")
+	}
+}
+
+type noFlush struct {
+	http.ResponseWriter
+}
+
+func TestStreamClosed(t *testing.T) {
+	w := httptest.NewRecorder()
+	stream, err := Stream(context.Background(), w, httptest.NewRequest("GET", "/", nil))
+	assert.NoError(t, err)
+	assert.NoError(t, stream.Comment("hello"))
+	stream.Close()
+	assert.Equal(t, ErrClosed, stream.Send("", "", "late"))
+	assert.Equal(t, ": hello\n\n", w.Body.String())
+
+	// The handler can still report the error when flushing is unsupported.
+	w = httptest.NewRecorder()
+	_, err = Stream(context.Background(), noFlush{w}, httptest.NewRequest("GET", "/", nil))
+	assert.Error(t, err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestStreamLineBreaks(t *testing.T) {
+	w := httptest.NewRecorder()
+	stream, err := Stream(context.Background(), w, httptest.NewRequest("GET", "/", nil))
+	assert.NoError(t, err)
+	assert.NoError(t, stream.Send("message", "1", "x\revent: admin\rid: 9\r\ny\nz"))
+	stream.Close()
+	assert.Equal(t, "event: message\nid: 1\ndata: x\ndata: event: admin\ndata: id: 9\ndata: y\ndata: z\n\n",
+		w.Body.String())
+}
+
+func TestStreamClearsWriteDeadline(t *testing.T) {
+	r := xrouter.New()
+	r.GET("/events", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		stream, err := Stream(ctx, w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer stream.Close()
+		time.Sleep(100 * time.Millisecond)
+		stream.Send("", "", "late")
+	})
+	server := httptest.NewUnstartedServer(r.Handler())
+	server.Config.WriteTimeout = 20 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/events")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer res.Body.Close()
+	assert.Equal(t, []string{"data: late"}, readEvent(t, bufio.NewReader(res.Body)))
+}
+
+func TestStreamEndsOnShutdown(t *testing.T) {
+	ended := make(chan struct{})
+	r := xrouter.New()
+	r.GET("/events", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		stream, err := Stream(ctx, w, r)
+		if err != nil {
+			return
+		}
+		defer stream.Close()
+		<-stream.Done()
+		close(ended)
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- r.Serve(ctx, xrouter.ServerOptions{Listener: ln, ShutdownTimeout: time.Second})
+	}()
+
+	res, err := http.Get("http://" + ln.Addr().String() + "/events")
+	cancel()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer res.Body.Close()
+	<-ended
+	assert.NoError(t, <-done)
+}
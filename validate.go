@@ -0,0 +1,114 @@
+package xrouter
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// RouteError describes a route which could not be registered, usually because it conflicts with an existing route.
+type RouteError struct {
+	// Route is the route which failed to register.
+	Route RouteInfo
+
+	// Conflict is the previously registered route which the new route conflicts with, if it could be determined.
+	Conflict *RouteInfo
+
+	// Reason is the message reported by httprouter.
+	Reason string
+}
+
+func (e *RouteError) Error() string {
+	msg := "xrouter: " + describeRoute(e.Route)
+	if e.Conflict != nil {
+		msg += " conflicts with " + describeRoute(*e.Conflict)
+	}
+	return msg + ": " + e.Reason
+}
+
+// describeRoute formats a route along with the group and source location which registered it.
+func describeRoute(info RouteInfo) string {
+	group := info.Group
+	if group == "" {
+		group = "/"
+	}
+	return fmt.Sprintf("%s %s (group %s, %s)", info.Method, info.Path, group, info.Source)
+}
+
+// register adds the route to httprouter, converting registration panics into a RouteError.
+func (r *routerGroup) register(info RouteInfo, h httprouter.Handle) (err *RouteError) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &RouteError{Route: info, Conflict: r.table.conflict(info), Reason: fmt.Sprint(v)}
+		}
+	}()
+	r.router.Handle(info.Method, info.Path, h)
+	return nil
+}
+
+// conflict returns the first registered route with the same method whose path cannot coexist with the given route.
+func (t *routeTable) conflict(info RouteInfo) *RouteInfo {
+	for i := range t.routes {
+		if t.routes[i].Method == info.Method && pathsConflict(t.routes[i].Path, info.Path) {
+			route := t.routes[i]
+			return &route
+		}
+	}
+	return nil
+}
+
+// pathsConflict reports whether httprouter would refuse to register both paths for the same method. Paths conflict
+// when they are identical or when a wildcard shares a position with a static segment or a differently named wildcard.
+func pathsConflict(a, b string) bool {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		sa, sb := as[i], bs[i]
+		wa := strings.HasPrefix(sa, ":") || strings.HasPrefix(sa, "*")
+		wb := strings.HasPrefix(sb, ":") || strings.HasPrefix(sb, "*")
+		switch {
+		case wa && wb:
+			if sa != sb || sa[0] == '*' {
+				return true
+			}
+		case wa || wb:
+			return true
+		case sa != sb:
+			return false
+		}
+	}
+	return len(as) == len(bs)
+}
+
+// Validate returns the errors collected while registering routes. It always returns nil unless the router was
+// created with the CollectErrors option, as registration errors panic otherwise.
+func (r *router) Validate() error {
+	errs := make([]error, len(r.group.table.errors))
+	for i, err := range r.group.table.errors {
+		errs[i] = err
+	}
+	return errors.Join(errs...)
+}
+
+// packagePath is the import path of this package, used to find the caller which registered a route.
+var packagePath = reflect.TypeOf(router{}).PkgPath()
+
+// caller returns the file:line of the first caller outside of this package and its subpackages, so routes registered
+// on the application's behalf, such as by the health package, are reported where the application asked for them.
+func caller() string {
+	pc := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pc[:runtime.Callers(2, pc)])
+	for {
+		frame, more := frames.Next()
+		internal := strings.HasPrefix(frame.Function, packagePath+".") || strings.HasPrefix(frame.Function, packagePath+"/")
+		if !internal || strings.HasSuffix(frame.File, "_test.go") {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
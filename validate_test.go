@@ -0,0 +1,61 @@
+package xrouter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathsConflict(t *testing.T) {
+	assert.True(t, pathsConflict("/users/:id", "/users/new"))
+	assert.True(t, pathsConflict("/users/:id", "/users/:name"))
+	assert.True(t, pathsConflict("/users/:id", "/users/:id"))
+	assert.True(t, pathsConflict("/files/*path", "/files/:name"))
+	assert.False(t, pathsConflict("/users/:id", "/users/:id/info"))
+	assert.False(t, pathsConflict("/users/list", "/users/new"))
+	assert.False(t, pathsConflict("/users", "/groups"))
+}
+
+func TestCollectErrors(t *testing.T) {
+	var events []RouteErrorEvent
+	r := New(CollectErrors())
+	r.EventHandler(func(evt Event) {
+		if e, ok := evt.(RouteErrorEvent); ok {
+			events = append(events, e)
+		}
+	})
+
+	users := r.Group("/users")
+	users.GET("/:id", GetTest)
+	users.GET("/new", GetTest)
+	users.GET("/:id", GetTest)
+	r.GET("/health", GetTest)
+
+	assert.Equal(t, 2, len(r.Routes()))
+	assert.Equal(t, 2, len(events))
+
+	err := r.Validate()
+	assert.Error(t, err)
+	lines := strings.Split(err.Error(), "\n")
+	assert.Equal(t, 2, len(lines))
+	assert.Contains(t, lines[0], "GET /users/new (group /users, ")
+	assert.Contains(t, lines[0], "conflicts with GET /users/:id (group /users, ")
+	assert.Contains(t, lines[0], "validate_test.go:")
+
+	assert.Equal(t, "/users/:id", events[1].Err.Conflict.Path)
+	assert.Contains(t, events[1].Err.Route.Source, "validate_test.go:")
+}
+
+func TestRouteConflictPanics(t *testing.T) {
+	r := New()
+	r.GET("/users/:id", GetTest)
+	assert.NoError(t, r.Validate())
+
+	defer func() {
+		err, ok := recover().(*RouteError)
+		assert.True(t, ok, "panic should be a *RouteError")
+		assert.Equal(t, "/users/:id", err.Conflict.Path)
+	}()
+	r.GET("/users/new", GetTest)
+}
@@ -0,0 +1,216 @@
+package xrouter
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// VersionOptions configures a VersionedAPI.
+type VersionOptions struct {
+	// Versions lists the API versions, oldest first.
+	Versions []string
+
+	// Default is the version used when a request does not select one. Defaults to the latest version.
+	Default string
+
+	// PathPrefix registers every route once for each version under a prefix named after the version, such as /v1.
+	PathPrefix bool
+
+	// Header is a request header which selects the version, such as X-API-Version.
+	Header string
+
+	// Vendor selects the version from vendor media types in the Accept header. A vendor of "vnd.example" matches
+	// application/vnd.example.v2+json.
+	Vendor string
+
+	// Deprecated maps deprecated versions to their deprecation details.
+	Deprecated map[string]Deprecation
+}
+
+// Versions maps API versions to the handler of a route in that version.
+type Versions map[string]Route
+
+// VersionedAPI registers routes which have different implementations in different API versions. A version without its
+// own handler uses the handler of the closest earlier version, so only the routes which change need new handlers.
+//
+//	api := xrouter.NewVersionedAPI(r.Group("/api"), xrouter.VersionOptions{
+//		Versions:   []string{"v1", "v2"},
+//		PathPrefix: true,
+//		Vendor:     "vnd.example",
+//	})
+//	api.GET("/users", xrouter.Versions{"v1": listUsers, "v2": listUsersV2})
+//	api.GET("/users/:id", xrouter.Versions{"v1": getUser})
+type VersionedAPI struct {
+	group RouterGroup
+	opts  VersionOptions
+}
+
+// NewVersionedAPI creates a VersionedAPI which registers its routes on the group. Routes without a version prefix are
+// registered when versions are selected by header or media type, or when path prefixes are disabled.
+func NewVersionedAPI(group RouterGroup, opts VersionOptions) *VersionedAPI {
+	if len(opts.Versions) == 0 {
+		panic("xrouter: versioned API without versions")
+	}
+	if opts.Default == "" {
+		opts.Default = opts.Versions[len(opts.Versions)-1]
+	}
+	return &VersionedAPI{group: group, opts: opts}
+}
+
+// VersionFromContext returns the API version selected for the request.
+func VersionFromContext(ctx context.Context) string {
+	version, _ := ctx.Value(versionKey).(string)
+	return version
+}
+
+// Handle registers the handlers of a route for each version.
+func (a *VersionedAPI) Handle(method, path string, handlers Versions, opts ...RouteOption) {
+	for version := range handlers {
+		if !slices.Contains(a.opts.Versions, version) {
+			panic("xrouter: unknown API version " + version + " for " + method + " " + path)
+		}
+	}
+
+	var served []string
+	for _, version := range a.opts.Versions {
+		handler := a.resolve(version, handlers)
+		if handler == nil {
+			continue
+		}
+		served = append(served, version)
+		if a.opts.PathPrefix {
+			a.group.Handle(method, "/"+version+path, a.serve(version, handler), a.routeOptions([]string{version}, opts)...)
+		}
+	}
+
+	if !a.opts.PathPrefix || a.opts.Header != "" || a.opts.Vendor != "" {
+		a.group.Handle(method, path, a.negotiated(handlers), a.routeOptions(served, opts)...)
+	}
+}
+
+// GET registers the handlers of a GET route for each version.
+func (a *VersionedAPI) GET(path string, handlers Versions, opts ...RouteOption) {
+	a.Handle("GET", path, handlers, opts...)
+}
+
+// POST registers the handlers of a POST route for each version.
+func (a *VersionedAPI) POST(path string, handlers Versions, opts ...RouteOption) {
+	a.Handle("POST", path, handlers, opts...)
+}
+
+// PUT registers the handlers of a PUT route for each version.
+func (a *VersionedAPI) PUT(path string, handlers Versions, opts ...RouteOption) {
+	a.Handle("PUT", path, handlers, opts...)
+}
+
+// PATCH registers the handlers of a PATCH route for each version.
+func (a *VersionedAPI) PATCH(path string, handlers Versions, opts ...RouteOption) {
+	a.Handle("PATCH", path, handlers, opts...)
+}
+
+// DELETE registers the handlers of a DELETE route for each version.
+func (a *VersionedAPI) DELETE(path string, handlers Versions, opts ...RouteOption) {
+	a.Handle("DELETE", path, handlers, opts...)
+}
+
+// routeOptions records the versions served by a route, and its deprecation when it only serves a deprecated version.
+func (a *VersionedAPI) routeOptions(versions []string, opts []RouteOption) []RouteOption {
+	return append([]RouteOption{func(info *RouteInfo) {
+		info.Versions = versions
+		if len(versions) == 1 {
+			if d, ok := a.opts.Deprecated[versions[0]]; ok {
+				info.Deprecation = &d
+			}
+		}
+	}}, opts...)
+}
+
+// resolve returns the handler for a version, falling back to earlier versions.
+func (a *VersionedAPI) resolve(version string, handlers Versions) Route {
+	for i := slices.Index(a.opts.Versions, version); i >= 0; i-- {
+		if h, ok := handlers[a.opts.Versions[i]]; ok {
+			return h
+		}
+	}
+	return nil
+}
+
+// serve runs the handler of a version, adding the version to the context and the deprecation headers to the response.
+// Calls to deprecated versions are counted by the router's deprecation tracker.
+func (a *VersionedAPI) serve(version string, handler Route) Route {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		// Routes which only serve a deprecated version add the headers and count the calls themselves.
+		if d, ok := a.opts.Deprecated[version]; ok && !routeDeprecated(ctx) {
+			d.writeHeaders(w.Header())
+			t, tracked := ctx.Value(deprecationKey).(*deprecationTracker)
+			if info, ok := RouteFromContext(ctx); ok && tracked {
+				t.track(r, info.Method, info.Path, version, &d)
+			}
+		}
+		ctx = context.WithValue(ctx, versionKey, version)
+		handler(ctx, w, r.WithContext(ctx))
+	}
+}
+
+// negotiated selects the version from the request headers.
+func (a *VersionedAPI) negotiated(handlers Versions) Route {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		if a.opts.Header != "" {
+			w.Header().Add("Vary", a.opts.Header)
+		}
+		if a.opts.Vendor != "" {
+			w.Header().Add("Vary", "Accept")
+		}
+
+		version, status := a.negotiate(r)
+		if status != 0 {
+			WriteError(w, status, "unsupported API version: "+version)
+			return
+		}
+		handler := a.resolve(version, handlers)
+		if handler == nil {
+			WriteError(w, http.StatusNotFound, "route not available in API version "+version)
+			return
+		}
+		a.serve(version, handler)(ctx, w, r)
+	}
+}
+
+// negotiate returns the requested version, preferring the custom header over the Accept header. Unknown versions are
+// returned with the status used to reject them.
+func (a *VersionedAPI) negotiate(r *http.Request) (string, int) {
+	if a.opts.Header != "" {
+		if version := strings.TrimSpace(r.Header.Get(a.opts.Header)); version != "" {
+			if !slices.Contains(a.opts.Versions, version) {
+				return version, http.StatusBadRequest
+			}
+			return version, 0
+		}
+	}
+
+	if a.opts.Vendor != "" {
+		var requested string
+		for _, accept := range headerTokens(r.Header, "Accept") {
+			mediaType, _, err := mime.ParseMediaType(accept)
+			if err != nil {
+				continue
+			}
+			_, subtype, _ := strings.Cut(mediaType, "/")
+			subtype, _, _ = strings.Cut(subtype, "+")
+			if version, ok := strings.CutPrefix(subtype, a.opts.Vendor+"."); ok {
+				if slices.Contains(a.opts.Versions, version) {
+					return version, 0
+				}
+				requested = version
+			}
+		}
+		if requested != "" {
+			return requested, http.StatusNotAcceptable
+		}
+	}
+
+	return a.opts.Default, 0
+}
@@ -0,0 +1,126 @@
+package xrouter
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var sunset = time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func versionHandler(name string) Route {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(name + " " + VersionFromContext(ctx)))
+	}
+}
+
+func newVersionedRouter(opts VersionOptions) Router {
+	r := New()
+	opts.Versions = []string{"v1", "v2", "v3"}
+	opts.Deprecated = map[string]Deprecation{"v1": {Since: time.Unix(1700000000, 0), Sunset: sunset, Link: "https://example.com/v1"}}
+	api := NewVersionedAPI(r.Group("/api"), opts)
+	api.GET("/users", Versions{"v1": versionHandler("users1"), "v3": versionHandler("users3")})
+	api.GET("/teams", Versions{"v2": versionHandler("teams2")})
+	return r
+}
+
+func TestVersionedPathPrefix(t *testing.T) {
+	r := newVersionedRouter(VersionOptions{PathPrefix: true})
+
+	w := send(r, "GET", "/api/v2/users", nil)
+	assert.Equal(t, "users1 v2", w.Body.String())
+	assert.Empty(t, w.Header().Get("Deprecation"))
+
+	w = send(r, "GET", "/api/v3/users", nil)
+	assert.Equal(t, "users3 v3", w.Body.String())
+
+	w = send(r, "GET", "/api/v1/teams", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = send(r, "GET", "/api/users", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestVersionedDeprecationHeaders(t *testing.T) {
+	r := newVersionedRouter(VersionOptions{PathPrefix: true})
+
+	w := send(r, "GET", "/api/v1/users", nil)
+	assert.Equal(t, "users1 v1", w.Body.String())
+	assert.Equal(t, "@1700000000", w.Header().Get("Deprecation"))
+	assert.Equal(t, "Fri, 01 Jan 2027 00:00:00 GMT", w.Header().Get("Sunset"))
+	assert.Equal(t, `<https://example.com/v1>; rel="deprecation"`, w.Header().Get("Link"))
+
+	info, _, ok := r.Lookup("GET", "/api/v1/users")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"v1"}, info.Versions)
+	assert.NotNil(t, info.Deprecation)
+
+	info, _, _ = r.Lookup("GET", "/api/v2/users")
+	assert.Nil(t, info.Deprecation)
+}
+
+func TestVersionedHeader(t *testing.T) {
+	r := newVersionedRouter(VersionOptions{Header: "X-API-Version", Default: "v2"})
+
+	w := send(r, "GET", "/api/users", nil)
+	assert.Equal(t, "users1 v2", w.Body.String())
+	assert.Equal(t, "X-API-Version", w.Header().Get("Vary"))
+
+	w = send(r, "GET", "/api/users", nil, "X-Api-Version", "v3")
+	assert.Equal(t, "users3 v3", w.Body.String())
+
+	w = send(r, "GET", "/api/users", nil, "X-Api-Version", "v1")
+	assert.Equal(t, "users1 v1", w.Body.String())
+	assert.Equal(t, "@1700000000", w.Header().Get("Deprecation"))
+
+	assert.Equal(t, []DeprecatedCalls{
+		{Method: "GET", Path: "/api/users", Version: "v1", Calls: 1, Callers: map[string]uint64{"192.0.2.1": 1}},
+	}, r.DeprecatedCalls())
+
+	w = send(r, "GET", "/api/users", nil, "X-Api-Version", "v9")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = send(r, "GET", "/api/teams", nil, "X-Api-Version", "v1")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	info, _, _ := r.Lookup("GET", "/api/teams")
+	assert.Equal(t, []string{"v2", "v3"}, info.Versions)
+}
+
+func TestVersionedMediaType(t *testing.T) {
+	r := newVersionedRouter(VersionOptions{Vendor: "vnd.example", PathPrefix: true})
+
+	w := send(r, "GET", "/api/users", nil, "Accept", "application/vnd.example.v1+json")
+	assert.Equal(t, "users1 v1", w.Body.String())
+
+	w = send(r, "GET", "/api/users", nil, "Accept", "text/html, application/vnd.example.v3+json;q=0.9")
+	assert.Equal(t, "users3 v3", w.Body.String())
+
+	w = send(r, "GET", "/api/users", nil, "Accept", "application/json")
+	assert.Equal(t, "users3 v3", w.Body.String())
+
+	w = send(r, "GET", "/api/users", nil, "Accept", "application/vnd.example.v7+json")
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+
+	w = send(r, "GET", "/api/v2/users", nil)
+	assert.Equal(t, "users1 v2", w.Body.String())
+}
+
+func TestVersionedUnknownVersion(t *testing.T) {
+	api := NewVersionedAPI(New(), VersionOptions{Versions: []string{"v1"}})
+	assert.Panics(t, func() {
+		api.GET("/users", Versions{"v2": versionHandler("users")})
+	})
+}
+
+func TestOpenAPIDeprecated(t *testing.T) {
+	r := newVersionedRouter(VersionOptions{PathPrefix: true})
+	doc := GenerateOpenAPI(OpenAPIOptions{}, r.Routes())
+
+	paths := doc["paths"].(map[string]interface{})
+	assert.Equal(t, true, paths["/api/v1/users"].(map[string]interface{})["get"].(map[string]interface{})["deprecated"])
+	assert.NotContains(t, paths["/api/v2/users"].(map[string]interface{})["get"], "deprecated")
+}
@@ -0,0 +1,522 @@
+package xrouter
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// WebSocketHandler handles an upgraded WebSocket connection. The connection is closed when the handler returns and
+// the context is cancelled when the connection fails.
+type WebSocketHandler func(ctx context.Context, conn *Conn)
+
+// WebSocketOptions configures WebSocket routes. Zero values use the defaults listed for each field.
+type WebSocketOptions struct {
+	// Origins lists the allowed values of the Origin header, or "*" for any origin. When empty only requests whose
+	// Origin matches the Host header, or which send no Origin, are accepted.
+	Origins []string
+
+	// Subprotocols lists the supported subprotocols in order of preference.
+	Subprotocols []string
+
+	// PingInterval is how often pings are sent to keep the connection alive. Defaults to 30 seconds.
+	PingInterval time.Duration
+
+	// PongTimeout is how long to wait for a pong, or any other frame, after a ping before the connection is
+	// considered dead. Defaults to 10 seconds.
+	PongTimeout time.Duration
+
+	// WriteTimeout limits how long writing a single frame may take. Defaults to 10 seconds.
+	WriteTimeout time.Duration
+
+	// ReadLimit is the maximum size of a message in bytes. Defaults to 1MB.
+	ReadLimit int64
+}
+
+func (o *WebSocketOptions) setDefaults() {
+	if o.PingInterval == 0 {
+		o.PingInterval = 30 * time.Second
+	}
+	if o.PongTimeout == 0 {
+		o.PongTimeout = 10 * time.Second
+	}
+	if o.WriteTimeout == 0 {
+		o.WriteTimeout = 10 * time.Second
+	}
+	if o.ReadLimit == 0 {
+		o.ReadLimit = 1 << 20
+	}
+}
+
+// WebSocketConfig sets the options of a WebSocket route.
+func WebSocketConfig(opts WebSocketOptions) RouteOption {
+	return func(info *RouteInfo) {
+		info.WebSocket = &opts
+	}
+}
+
+// MessageType is the type of a WebSocket data message.
+type MessageType int
+
+// Data message types.
+const (
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+)
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// Close codes defined by RFC 6455.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+)
+
+// maxCloseReason is the longest close reason which fits in a control frame after the two byte code.
+const maxCloseReason = 123
+
+// CloseError is returned when the connection has been closed with a close frame.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return "websocket: closed with code " + strconv.Itoa(e.Code) + " " + e.Reason
+}
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocketRoute adapts a WebSocketHandler to a Route which performs the upgrade. Options are read from the route's
+// WebSocketConfig.
+func WebSocketRoute(handler WebSocketHandler) Route {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		var opts WebSocketOptions
+		if info, ok := RouteFromContext(ctx); ok && info.WebSocket != nil {
+			opts = *info.WebSocket
+		}
+		opts.setDefaults()
+
+		conn, err := upgrade(w, r, &opts)
+		if err != nil {
+			return
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		conn.cancel = cancel
+		conn.shutdown = ShutdownNotify(ctx)
+		defer conn.Close()
+
+		go conn.keepalive(opts.PingInterval)
+		handler(ctx, conn)
+	}
+}
+
+// upgrade performs the opening handshake. Failed handshakes are answered with an HTTP error.
+func upgrade(w http.ResponseWriter, r *http.Request, opts *WebSocketOptions) (*Conn, error) {
+	fail := func(status int, msg string) (*Conn, error) {
+		WriteError(w, status, msg)
+		return nil, errors.New(msg)
+	}
+
+	if r.Method != "GET" {
+		return fail(http.StatusMethodNotAllowed, "websocket: method must be GET")
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		return fail(http.StatusBadRequest, "websocket: not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return fail(http.StatusUpgradeRequired, "websocket: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return fail(http.StatusBadRequest, "websocket: invalid Sec-WebSocket-Key")
+	}
+	if !checkOrigin(r, opts.Origins) {
+		return fail(http.StatusForbidden, "websocket: origin not allowed")
+	}
+
+	subprotocol := ""
+	offered := headerTokens(r.Header, "Sec-WebSocket-Protocol")
+	for _, supported := range opts.Subprotocols {
+		if subprotocol == "" && slices.Contains(offered, supported) {
+			subprotocol = supported
+		}
+	}
+
+	netConn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return fail(http.StatusInternalServerError, "websocket: hijacking unsupported")
+	}
+
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	resp := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n"
+	if subprotocol != "" {
+		resp += "Sec-WebSocket-Protocol: " + subprotocol + "\r\n"
+	}
+	resp += "\r\n"
+
+	netConn.SetDeadline(time.Time{})
+	if _, err := rw.WriteString(resp); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	if err := rw.Flush(); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	return &Conn{
+		conn:         netConn,
+		br:           rw.Reader,
+		bw:           rw.Writer,
+		subprotocol:  subprotocol,
+		readLimit:    opts.ReadLimit,
+		readTimeout:  opts.PingInterval + opts.PongTimeout,
+		writeTimeout: opts.WriteTimeout,
+		done:         make(chan struct{}),
+	}, nil
+}
+
+// checkOrigin applies the origin policy. Requests without an Origin header are not from browsers and are allowed.
+func checkOrigin(r *http.Request, allowed []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if len(allowed) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+	for _, o := range allowed {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func headerTokens(h http.Header, name string) []string {
+	var tokens []string
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tokens = append(tokens, t)
+			}
+		}
+	}
+	return tokens
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, t := range headerTokens(h, name) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// Conn is a server side WebSocket connection. Reads must happen from a single goroutine, while writes are safe for
+// concurrent use.
+type Conn struct {
+	conn         net.Conn
+	br           *bufio.Reader
+	subprotocol  string
+	readLimit    int64
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	cancel       context.CancelFunc
+	shutdown     <-chan struct{}
+
+	wmu       sync.Mutex
+	bw        *bufio.Writer
+	closeSent bool
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// Subprotocol returns the negotiated subprotocol, if any.
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// RemoteAddr returns the address of the client.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// ReadMessage reads the next data message, answering pings and reassembling fragmented messages. It returns a
+// *CloseError once the client closes the connection.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	var msgType MessageType
+	var msg []byte
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, c.readFailed(err)
+		}
+
+		switch op {
+		case opPing:
+			c.writeFrame(opPong, payload)
+			continue
+		case opPong:
+			continue
+		case opClose:
+			code, reason := CloseNoStatus, ""
+			if len(payload) >= 2 {
+				code, reason = int(binary.BigEndian.Uint16(payload)), string(payload[2:])
+				if !validCloseCode(code) {
+					return 0, nil, c.fail(CloseProtocolError, "invalid close code")
+				}
+				if !utf8.ValidString(reason) {
+					return 0, nil, c.fail(CloseInvalidPayload, "invalid utf-8")
+				}
+			} else if len(payload) == 1 {
+				return 0, nil, c.fail(CloseProtocolError, "invalid close frame")
+			}
+			// 1005 only reports a missing status and must not be sent, so a close without one is answered normally.
+			reply := code
+			if reply == CloseNoStatus {
+				reply = CloseNormal
+			}
+			c.CloseWithReason(reply, "")
+			return 0, nil, c.readFailed(&CloseError{code, reason})
+		case opText, opBinary:
+			if msgType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "expected continuation frame")
+			}
+			msgType = MessageType(op)
+		case opContinuation:
+			if msgType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		default:
+			return 0, nil, c.fail(CloseProtocolError, "unknown opcode")
+		}
+
+		msg = append(msg, payload...)
+		if int64(len(msg)) > c.readLimit {
+			return 0, nil, c.fail(CloseMessageTooBig, "message too big")
+		}
+		if fin {
+			if msgType == TextMessage && !utf8.Valid(msg) {
+				return 0, nil, c.fail(CloseInvalidPayload, "invalid utf-8")
+			}
+			return msgType, msg, nil
+		}
+	}
+}
+
+// ReadJSON reads the next message and decodes it as JSON.
+func (c *Conn) ReadJSON(v interface{}) error {
+	_, msg, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(msg, v)
+}
+
+// WriteMessage writes a data message in a single frame.
+func (c *Conn) WriteMessage(t MessageType, data []byte) error {
+	return c.writeFrame(byte(t), data)
+}
+
+// WriteJSON encodes v as JSON and writes it as a text message.
+func (c *Conn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(TextMessage, data)
+}
+
+// Close sends a normal close frame and closes the connection.
+func (c *Conn) Close() error {
+	return c.CloseWithReason(CloseNormal, "")
+}
+
+// CloseWithReason sends a close frame with the given code and reason and closes the connection. Reasons longer than
+// maxCloseReason bytes are truncated to fit in the control frame.
+func (c *Conn) CloseWithReason(code int, reason string) error {
+	if len(reason) > maxCloseReason {
+		n := maxCloseReason
+		for n > 0 && !utf8.RuneStart(reason[n]) {
+			n--
+		}
+		reason = reason[:n]
+	}
+
+	var err error
+	c.closeOnce.Do(func() {
+		payload := make([]byte, 2, 2+len(reason))
+		binary.BigEndian.PutUint16(payload, uint16(code))
+		c.writeFrame(opClose, append(payload, reason...))
+		close(c.done)
+		if c.cancel != nil {
+			c.cancel()
+		}
+		err = c.conn.Close()
+	})
+	return err
+}
+
+// validCloseCode reports whether a close code received from the client may appear on the wire: the codes assigned by
+// RFC 6455 and the IANA registry, except those reserved for local use, and the ranges left to libraries and applications.
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1014:
+		return code != 1004 && code != CloseNoStatus && code != 1006
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+// fail closes the connection because of a protocol violation by the client.
+func (c *Conn) fail(code int, reason string) error {
+	c.CloseWithReason(code, reason)
+	return c.readFailed(&CloseError{code, reason})
+}
+
+// readFailed cancels the handler context since the connection can no longer be used.
+func (c *Conn) readFailed(err error) error {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	return err
+}
+
+func (c *Conn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	if c.readTimeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+	}
+
+	var header [2]byte
+	if _, err = io.ReadFull(c.br, header[:]); err != nil {
+		return
+	}
+	fin, op = header[0]&0x80 != 0, header[0]&0x0f
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, c.fail(CloseProtocolError, "reserved bits set")
+	}
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, c.fail(CloseProtocolError, "client frames must be masked")
+	}
+
+	length := int64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+
+	if op >= opClose && (length > 125 || !fin) {
+		return false, 0, nil, c.fail(CloseProtocolError, "invalid control frame")
+	}
+	if length < 0 || length > c.readLimit {
+		return false, 0, nil, c.fail(CloseMessageTooBig, "message too big")
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+func (c *Conn) writeFrame(op byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return &CloseError{CloseNormal, "connection closed"}
+	}
+	if op == opClose {
+		c.closeSent = true
+	}
+
+	if c.writeTimeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+
+	c.bw.WriteByte(0x80 | op)
+	switch n := len(payload); {
+	case n <= 125:
+		c.bw.WriteByte(byte(n))
+	case n <= 0xffff:
+		c.bw.WriteByte(126)
+		binary.Write(c.bw, binary.BigEndian, uint16(n))
+	default:
+		c.bw.WriteByte(127)
+		binary.Write(c.bw, binary.BigEndian, uint64(n))
+	}
+	c.bw.Write(payload)
+	return c.bw.Flush()
+}
+
+// keepalive pings the client until the connection is closed, and closes it when the server shuts down.
+func (c *Conn) keepalive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-c.shutdown:
+			c.CloseWithReason(CloseGoingAway, "server shutting down")
+			return
+		case <-ticker.C:
+			if c.writeFrame(opPing, nil) != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package xrouter
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// wsClient is a minimal WebSocket client which writes masked frames and reads raw frames.
+type wsClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func dialWebSocket(t *testing.T, srv *httptest.Server, path string, header http.Header) (*wsClient, *http.Response) {
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req, _ := http.NewRequest("GET", srv.URL+path, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Write(conn)
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return &wsClient{conn, br}, resp
+}
+
+func (c *wsClient) writeFrame(fin bool, op byte, payload []byte) {
+	b0 := op
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, 0x80|byte(n))
+	default:
+		frame = append(frame, 0x80|126, byte(n>>8), byte(n))
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	c.conn.Write(frame)
+}
+
+func (c *wsClient) readFrame() (op byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.br, header[:]); err != nil {
+		return
+	}
+	length := int(header[1] & 0x7f)
+	if length == 126 {
+		var ext [2]byte
+		io.ReadFull(c.br, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload = make([]byte, length)
+	_, err = io.ReadFull(c.br, payload)
+	return header[0] & 0x0f, payload, err
+}
+
+func newWebSocketServer(opts ...RouteOption) *httptest.Server {
+	r := New()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "deny" {
+				WriteError(w, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	r.WebSocket("/echo", func(ctx context.Context, conn *Conn) {
+		for {
+			t, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if string(msg) == "close" {
+				conn.CloseWithReason(CloseGoingAway, strings.Repeat("é", 100))
+				return
+			}
+			if string(msg) == "protocol" {
+				conn.WriteMessage(TextMessage, []byte(conn.Subprotocol()))
+				continue
+			}
+			conn.WriteMessage(t, msg)
+		}
+	}, opts...)
+	return httptest.NewServer(r.Handler())
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	srv := newWebSocketServer()
+	defer srv.Close()
+
+	c, resp := dialWebSocket(t, srv, "/echo", nil)
+	defer c.conn.Close()
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"))
+	assert.Equal(t, "websocket", resp.Header.Get("Upgrade"))
+}
+
+func TestWebSocketEcho(t *testing.T) {
+	srv := newWebSocketServer()
+	defer srv.Close()
+
+	c, _ := dialWebSocket(t, srv, "/echo", nil)
+	defer c.conn.Close()
+
+	c.writeFrame(true, opText, []byte("hello"))
+	op, payload, err := c.readFrame()
+	assert.NoError(t, err)
+	assert.Equal(t, byte(opText), op)
+	assert.Equal(t, "hello", string(payload))
+
+	large := []byte(strings.Repeat("x", 300))
+	c.writeFrame(true, opBinary, large)
+	op, payload, _ = c.readFrame()
+	assert.Equal(t, byte(opBinary), op)
+	assert.Equal(t, large, payload)
+}
+
+func TestWebSocketFragmentsAndPing(t *testing.T) {
+	srv := newWebSocketServer()
+	defer srv.Close()
+
+	c, _ := dialWebSocket(t, srv, "/echo", nil)
+	defer c.conn.Close()
+
+	c.writeFrame(false, opText, []byte("hel"))
+	c.writeFrame(true, opPing, []byte("p"))
+	c.writeFrame(true, opContinuation, []byte("lo"))
+
+	op, payload, _ := c.readFrame()
+	assert.Equal(t, byte(opPong), op)
+	assert.Equal(t, "p", string(payload))
+
+	op, payload, _ = c.readFrame()
+	assert.Equal(t, byte(opText), op)
+	assert.Equal(t, "hello", string(payload))
+}
+
+func TestWebSocketClose(t *testing.T) {
+	srv := newWebSocketServer()
+	defer srv.Close()
+
+	c, _ := dialWebSocket(t, srv, "/echo", nil)
+	defer c.conn.Close()
+
+	c.writeFrame(true, opClose, []byte{0x03, 0xe8})
+	op, payload, _ := c.readFrame()
+	assert.Equal(t, byte(opClose), op)
+	assert.Equal(t, CloseNormal, int(binary.BigEndian.Uint16(payload)))
+
+	// A close frame without a status is answered with a normal closure.
+	c, _ = dialWebSocket(t, srv, "/echo", nil)
+	defer c.conn.Close()
+	c.writeFrame(true, opClose, nil)
+	op, payload, _ = c.readFrame()
+	assert.Equal(t, byte(opClose), op)
+	if assert.Len(t, payload, 2) {
+		assert.Equal(t, CloseNormal, int(binary.BigEndian.Uint16(payload)))
+	}
+
+	// Reserved and unassigned codes are protocol errors rather than being echoed back.
+	for _, code := range []int{999, 1004, CloseNoStatus, 1006, 1015, 2000, 5000} {
+		c, _ = dialWebSocket(t, srv, "/echo", nil)
+		defer c.conn.Close()
+		c.writeFrame(true, opClose, binary.BigEndian.AppendUint16(nil, uint16(code)))
+		op, payload, _ = c.readFrame()
+		assert.Equal(t, byte(opClose), op)
+		assert.Equal(t, CloseProtocolError, int(binary.BigEndian.Uint16(payload)), code)
+	}
+	c, _ = dialWebSocket(t, srv, "/echo", nil)
+	defer c.conn.Close()
+	c.writeFrame(true, opClose, binary.BigEndian.AppendUint16(nil, 4000))
+	op, payload, _ = c.readFrame()
+	assert.Equal(t, byte(opClose), op)
+	assert.Equal(t, 4000, int(binary.BigEndian.Uint16(payload)))
+
+	// Long reasons are cut to fit the control frame without splitting a character.
+	c, _ = dialWebSocket(t, srv, "/echo", nil)
+	defer c.conn.Close()
+	c.writeFrame(true, opText, []byte("close"))
+	op, payload, _ = c.readFrame()
+	assert.Equal(t, byte(opClose), op)
+	assert.Len(t, payload, 2+122)
+	assert.True(t, utf8.Valid(payload[2:]))
+}
+
+func TestWebSocketProtocolErrors(t *testing.T) {
+	srv := newWebSocketServer(WebSocketConfig(WebSocketOptions{ReadLimit: 10}))
+	defer srv.Close()
+
+	c, _ := dialWebSocket(t, srv, "/echo", nil)
+	c.writeFrame(true, opText, []byte("this message is too long"))
+	op, payload, _ := c.readFrame()
+	assert.Equal(t, byte(opClose), op)
+	assert.Equal(t, CloseMessageTooBig, int(binary.BigEndian.Uint16(payload)))
+	c.conn.Close()
+
+	c, _ = dialWebSocket(t, srv, "/echo", nil)
+	c.writeFrame(true, opText, []byte{0xff, 0xfe})
+	op, payload, _ = c.readFrame()
+	assert.Equal(t, byte(opClose), op)
+	assert.Equal(t, CloseInvalidPayload, int(binary.BigEndian.Uint16(payload)))
+	c.conn.Close()
+
+	// Unmasked client frame.
+	c, _ = dialWebSocket(t, srv, "/echo", nil)
+	c.conn.Write([]byte{0x81, 0x01, 'x'})
+	op, payload, _ = c.readFrame()
+	assert.Equal(t, byte(opClose), op)
+	assert.Equal(t, CloseProtocolError, int(binary.BigEndian.Uint16(payload)))
+	c.conn.Close()
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	srv := newWebSocketServer()
+	defer srv.Close()
+
+	c, resp := dialWebSocket(t, srv, "/echo", http.Header{"Origin": {"http://evil.example.com"}})
+	c.conn.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	c, resp = dialWebSocket(t, srv, "/echo", http.Header{"Origin": {srv.URL}})
+	c.conn.Close()
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	allowed := newWebSocketServer(WebSocketConfig(WebSocketOptions{Origins: []string{"https://app.example.com"}}))
+	defer allowed.Close()
+
+	c, resp = dialWebSocket(t, allowed, "/echo", http.Header{"Origin": {"https://app.example.com"}})
+	c.conn.Close()
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+}
+
+func TestWebSocketSubprotocol(t *testing.T) {
+	srv := newWebSocketServer(WebSocketConfig(WebSocketOptions{Subprotocols: []string{"v2.chat", "v1.chat"}}))
+	defer srv.Close()
+
+	c, resp := dialWebSocket(t, srv, "/echo", http.Header{"Sec-Websocket-Protocol": {"v1.chat, v2.chat"}})
+	defer c.conn.Close()
+	assert.Equal(t, "v2.chat", resp.Header.Get("Sec-WebSocket-Protocol"))
+
+	c.writeFrame(true, opText, []byte("protocol"))
+	_, payload, _ := c.readFrame()
+	assert.Equal(t, "v2.chat", string(payload))
+}
+
+func TestWebSocketKeepalive(t *testing.T) {
+	srv := newWebSocketServer(WebSocketConfig(WebSocketOptions{PingInterval: 20 * time.Millisecond}))
+	defer srv.Close()
+
+	c, _ := dialWebSocket(t, srv, "/echo", nil)
+	defer c.conn.Close()
+
+	op, _, err := c.readFrame()
+	assert.NoError(t, err)
+	assert.Equal(t, byte(opPing), op)
+}
+
+func TestWebSocketRejectsInvalidHandshake(t *testing.T) {
+	srv := newWebSocketServer()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/echo")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	c, resp := dialWebSocket(t, srv, "/echo", http.Header{"Sec-Websocket-Version": {"8"}})
+	c.conn.Close()
+	assert.Equal(t, http.StatusUpgradeRequired, resp.StatusCode)
+	assert.Equal(t, "13", resp.Header.Get("Sec-WebSocket-Version"))
+
+	c, resp = dialWebSocket(t, srv, "/echo", http.Header{"Authorization": {"deny"}})
+	c.conn.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestWebSocketRouteInfo(t *testing.T) {
+	r := New()
+	r.WebSocket("/ws", func(ctx context.Context, conn *Conn) {})
+
+	info, _, ok := r.Lookup("GET", "/ws")
+	assert.True(t, ok)
+	assert.NotNil(t, info.WebSocket)
+}
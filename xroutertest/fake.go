@@ -0,0 +1,428 @@
+package xroutertest
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/eliquious/xrouter"
+	"github.com/julienschmidt/httprouter"
+	"github.com/justinas/alice"
+)
+
+// Registration is a route which was registered with a FakeRouter.
+type Registration struct {
+	Info    xrouter.RouteInfo
+	Handler xrouter.Route
+}
+
+// FakeRouter is an xrouter.Router which records registrations so that tests can assert how a service configures its
+// routes. Its Handler serves the registered routes directly, without middleware.
+type FakeRouter struct {
+	*FakeGroup
+
+	// Events contains the events fired by the fake, in order.
+	Events []xrouter.Event
+
+	// NotFoundHandler, MethodNotAllowedHandler and StaticRootHandler hold the handlers set on the router.
+	NotFoundHandler         http.Handler
+	MethodNotAllowedHandler http.Handler
+	StaticRootHandler       http.Handler
+
+	// GroupNotFoundHandlers and GroupMethodNotAllowedHandlers map group prefixes to the handlers set on the groups.
+	GroupNotFoundHandlers         map[string]http.Handler
+	GroupMethodNotAllowedHandlers map[string]http.Handler
+
+	// StaticHandlers maps paths passed to StaticFiles to their handlers.
+	StaticHandlers map[string]http.Handler
+
+	// OpenAPIPaths lists the paths passed to ServeOpenAPI.
+	OpenAPIPaths []string
+
+	// StartHooks and ShutdownHooks hold the hooks added with OnStart and OnShutdown.
+	StartHooks    []xrouter.Hook
+	ShutdownHooks []xrouter.Hook
+
+	// Draining is returned by ShuttingDown of the router and its groups.
+	Draining bool
+
+	// Deprecations is returned by DeprecatedCalls.
+	Deprecations []xrouter.DeprecatedCalls
+
+	registrations []Registration
+	evtHandler    func(xrouter.Event)
+}
+
+var _ xrouter.Router = (*FakeRouter)(nil)
+
+// NewFakeRouter creates an empty FakeRouter.
+func NewFakeRouter() *FakeRouter {
+	f := &FakeRouter{
+		StaticHandlers:                make(map[string]http.Handler),
+		GroupNotFoundHandlers:         make(map[string]http.Handler),
+		GroupMethodNotAllowedHandlers: make(map[string]http.Handler),
+	}
+	f.FakeGroup = &FakeGroup{router: f}
+	return f
+}
+
+// Registrations returns every route registered with the router or its groups, in order.
+func (f *FakeRouter) Registrations() []Registration {
+	return append([]Registration(nil), f.registrations...)
+}
+
+// Registered returns the registration for the given method and route pattern, if it exists.
+func (f *FakeRouter) Registered(method, path string) (Registration, bool) {
+	for _, reg := range f.registrations {
+		if reg.Info.Method == method && reg.Info.Path == path {
+			return reg, true
+		}
+	}
+	return Registration{}, false
+}
+
+func (f *FakeRouter) fire(evt xrouter.Event) {
+	f.Events = append(f.Events, evt)
+	if f.evtHandler != nil {
+		f.evtHandler(evt)
+	}
+}
+
+// StaticRoot records the handler.
+func (f *FakeRouter) StaticRoot(fs http.Handler) {
+	f.StaticRootHandler = fs
+}
+
+// StaticFiles records the handler.
+func (f *FakeRouter) StaticFiles(path string, fs http.Handler) {
+	f.StaticHandlers[path] = fs
+}
+
+// NotFound records the handler.
+func (f *FakeRouter) NotFound(h http.Handler) {
+	f.NotFoundHandler = h
+	f.fire(xrouter.NotFoundHandlerEvent{})
+}
+
+// MethodNotAllowed records the handler.
+func (f *FakeRouter) MethodNotAllowed(h http.Handler) {
+	f.MethodNotAllowedHandler = h
+	f.fire(xrouter.MethodNotAllowedHandlerEvent{})
+}
+
+// Handler returns a handler which calls the registered route matching the request, with its URL params in the context.
+func (f *FakeRouter) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, reg := range f.registrations {
+			if reg.Info.Method != r.Method {
+				continue
+			}
+			if params, ok := matchPattern(reg.Info.Path, r.URL.Path); ok {
+				ctx := context.WithValue(r.Context(), xrouter.ParamsKey, params)
+				reg.Handler(ctx, w, r.WithContext(ctx))
+				return
+			}
+		}
+		if h := f.groupNotFound(r.URL.Path); h != nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if f.NotFoundHandler != nil {
+			f.NotFoundHandler.ServeHTTP(w, r)
+			return
+		}
+		http.NotFound(w, r)
+	})
+}
+
+// groupNotFound returns the NotFound handler of the group with the longest prefix containing the path.
+func (f *FakeRouter) groupNotFound(path string) http.Handler {
+	var handler http.Handler
+	longest := -1
+	for prefix, h := range f.GroupNotFoundHandlers {
+		if (path == prefix || strings.HasPrefix(path, prefix+"/")) && len(prefix) > longest {
+			handler, longest = h, len(prefix)
+		}
+	}
+	return handler
+}
+
+// EventHandler sets a function which is called for each event in addition to recording it.
+func (f *FakeRouter) EventHandler(h func(xrouter.Event)) {
+	f.evtHandler = h
+}
+
+// Routes returns the registered routes.
+func (f *FakeRouter) Routes() []xrouter.RouteInfo {
+	routes := make([]xrouter.RouteInfo, len(f.registrations))
+	for i, reg := range f.registrations {
+		routes[i] = reg.Info
+	}
+	return routes
+}
+
+// ServeOpenAPI records the path.
+func (f *FakeRouter) ServeOpenAPI(path string, opts xrouter.OpenAPIOptions) {
+	f.OpenAPIPaths = append(f.OpenAPIPaths, path)
+}
+
+// Lookup returns the first registered route whose pattern matches the path.
+func (f *FakeRouter) Lookup(method, path string) (xrouter.RouteInfo, httprouter.Params, bool) {
+	for _, reg := range f.registrations {
+		if reg.Info.Method != method {
+			continue
+		}
+		if params, ok := matchPattern(reg.Info.Path, path); ok {
+			return reg.Info, params, true
+		}
+	}
+	return xrouter.RouteInfo{}, nil, false
+}
+
+// Validate always returns nil since the fake does not detect conflicts.
+func (f *FakeRouter) Validate() error {
+	return nil
+}
+
+// Serve runs the start hooks, waits for the context to be cancelled and then runs the shutdown hooks. No listener is
+// opened.
+func (f *FakeRouter) Serve(ctx context.Context, opts xrouter.ServerOptions) error {
+	for _, hook := range f.StartHooks {
+		if err := hook(ctx); err != nil {
+			return err
+		}
+	}
+	<-ctx.Done()
+	f.Draining = true
+	for _, hook := range f.ShutdownHooks {
+		if err := hook(context.Background()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OnStart records the hook.
+func (f *FakeRouter) OnStart(h xrouter.Hook) {
+	f.StartHooks = append(f.StartHooks, h)
+}
+
+// OnShutdown records the hook.
+func (f *FakeRouter) OnShutdown(h xrouter.Hook) {
+	f.ShutdownHooks = append(f.ShutdownHooks, h)
+}
+
+// DeprecatedCalls returns Deprecations.
+func (f *FakeRouter) DeprecatedCalls() []xrouter.DeprecatedCalls {
+	return f.Deprecations
+}
+
+// FakeGroup is the xrouter.RouterGroup implementation used by FakeRouter.
+type FakeGroup struct {
+	// Middleware contains the middleware added to the group, including middleware inherited from its parent.
+	// MiddlewareNames holds the name of each middleware.
+	Middleware      []func(http.Handler) http.Handler
+	MiddlewareNames []string
+
+	// RouteDefaults contains the options added with Defaults, including options inherited from its parent.
+	RouteDefaults []xrouter.RouteOption
+
+	router *FakeRouter
+	prefix string
+}
+
+var _ xrouter.RouterGroup = (*FakeGroup)(nil)
+
+// Use records the middleware, named after the function which implements it with the same numeric suffix for repeated
+// middleware as the router.
+func (g *FakeGroup) Use(f func(next http.Handler) http.Handler) {
+	base := runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+	base = base[strings.LastIndex(base, "/")+1:]
+	name := base
+	for i := 2; slices.Contains(g.MiddlewareNames, name); i++ {
+		name = base + "#" + strconv.Itoa(i)
+	}
+	g.UseNamed(name, f)
+}
+
+// UseNamed records the named middleware.
+func (g *FakeGroup) UseNamed(name string, f func(next http.Handler) http.Handler) {
+	g.Middleware = append(g.Middleware, f)
+	g.MiddlewareNames = append(g.MiddlewareNames, name)
+}
+
+// UseBefore records the named middleware before the middleware called before, or last if there is none.
+func (g *FakeGroup) UseBefore(before, name string, f func(next http.Handler) http.Handler) {
+	g.insert(slices.Index(g.MiddlewareNames, before), name, f)
+}
+
+// UseAfter records the named middleware after the middleware called after, or last if there is none.
+func (g *FakeGroup) UseAfter(after, name string, f func(next http.Handler) http.Handler) {
+	i := slices.Index(g.MiddlewareNames, after)
+	if i >= 0 {
+		i++
+	}
+	g.insert(i, name, f)
+}
+
+func (g *FakeGroup) insert(i int, name string, f func(next http.Handler) http.Handler) {
+	if i < 0 {
+		g.UseNamed(name, f)
+		return
+	}
+	g.Middleware = slices.Insert(g.Middleware, i, f)
+	g.MiddlewareNames = slices.Insert(g.MiddlewareNames, i, name)
+}
+
+// Without removes the named middleware.
+func (g *FakeGroup) Without(names ...string) {
+	for i := len(g.MiddlewareNames) - 1; i >= 0; i-- {
+		if slices.Contains(names, g.MiddlewareNames[i]) {
+			g.Middleware = slices.Delete(g.Middleware, i, i+1)
+			g.MiddlewareNames = slices.Delete(g.MiddlewareNames, i, i+1)
+		}
+	}
+}
+
+// Chain returns a chain of the recorded middleware.
+func (g *FakeGroup) Chain() alice.Chain {
+	constructors := make([]alice.Constructor, len(g.Middleware))
+	for i, m := range g.Middleware {
+		constructors[i] = m
+	}
+	return alice.New(constructors...)
+}
+
+// Group returns a child group which shares the router's registrations. Unlike xrouter groups, the child copies the
+// middleware of its parent when it is created.
+func (g *FakeGroup) Group(path string) xrouter.RouterGroup {
+	return &FakeGroup{
+		Middleware:      slices.Clone(g.Middleware),
+		MiddlewareNames: slices.Clone(g.MiddlewareNames),
+		RouteDefaults:   slices.Clone(g.RouteDefaults),
+		router:          g.router,
+		prefix:          g.prefix + path,
+	}
+}
+
+// Defaults records options which are applied to routes later registered with the group.
+func (g *FakeGroup) Defaults(opts ...xrouter.RouteOption) {
+	g.RouteDefaults = append(g.RouteDefaults, opts...)
+}
+
+// ShuttingDown returns the Draining field of the router.
+func (g *FakeGroup) ShuttingDown() bool {
+	return g.router.Draining
+}
+
+// Path returns the prefix of the group.
+func (g *FakeGroup) Path() string {
+	if g.prefix == "" {
+		return "/"
+	}
+	return g.prefix
+}
+
+// Handle records the route.
+func (g *FakeGroup) Handle(method, path string, handler xrouter.Route, opts ...xrouter.RouteOption) {
+	info := xrouter.RouteInfo{Method: method, Path: g.prefix + path, Group: g.prefix, Middleware: slices.Clone(g.MiddlewareNames)}
+	for _, opt := range g.RouteDefaults {
+		opt(&info)
+	}
+	for _, opt := range opts {
+		opt(&info)
+	}
+	g.router.registrations = append(g.router.registrations, Registration{info, handler})
+	g.router.fire(xrouter.AddHandlerEvent{Method: method, Path: info.Path})
+}
+
+// GET records a GET route.
+func (g *FakeGroup) GET(path string, handler xrouter.Route, opts ...xrouter.RouteOption) {
+	g.Handle("GET", path, handler, opts...)
+}
+
+// POST records a POST route.
+func (g *FakeGroup) POST(path string, handler xrouter.Route, opts ...xrouter.RouteOption) {
+	g.Handle("POST", path, handler, opts...)
+}
+
+// PUT records a PUT route.
+func (g *FakeGroup) PUT(path string, handler xrouter.Route, opts ...xrouter.RouteOption) {
+	g.Handle("PUT", path, handler, opts...)
+}
+
+// OPTIONS records an OPTIONS route.
+func (g *FakeGroup) OPTIONS(path string, handler xrouter.Route, opts ...xrouter.RouteOption) {
+	g.Handle("OPTIONS", path, handler, opts...)
+}
+
+// HEAD records a HEAD route.
+func (g *FakeGroup) HEAD(path string, handler xrouter.Route, opts ...xrouter.RouteOption) {
+	g.Handle("HEAD", path, handler, opts...)
+}
+
+// PATCH records a PATCH route.
+func (g *FakeGroup) PATCH(path string, handler xrouter.Route, opts ...xrouter.RouteOption) {
+	g.Handle("PATCH", path, handler, opts...)
+}
+
+// DELETE records a DELETE route.
+func (g *FakeGroup) DELETE(path string, handler xrouter.Route, opts ...xrouter.RouteOption) {
+	g.Handle("DELETE", path, handler, opts...)
+}
+
+// WebSocket records a GET route which upgrades to a WebSocket connection.
+func (g *FakeGroup) WebSocket(path string, handler xrouter.WebSocketHandler, opts ...xrouter.RouteOption) {
+	opts = append([]xrouter.RouteOption{xrouter.WebSocketConfig(xrouter.WebSocketOptions{})}, opts...)
+	g.Handle("GET", path, xrouter.WebSocketRoute(handler), opts...)
+}
+
+// Proxy records a route for each method which forwards requests to the target.
+func (g *FakeGroup) Proxy(path string, target *url.URL, opts xrouter.ProxyOptions) {
+	route := xrouter.ProxyRoute(target, opts)
+	if !strings.Contains(path, "*") {
+		path = strings.TrimSuffix(path, "/") + "/*proxypath"
+	}
+	for _, method := range []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"} {
+		g.Handle(method, path, route)
+	}
+}
+
+// NotFound records the handler for the group's prefix.
+func (g *FakeGroup) NotFound(h http.Handler) {
+	g.router.GroupNotFoundHandlers[g.prefix] = h
+	g.router.fire(xrouter.NotFoundHandlerEvent{Group: g.prefix})
+}
+
+// MethodNotAllowed records the handler for the group's prefix.
+func (g *FakeGroup) MethodNotAllowed(h http.Handler) {
+	g.router.GroupMethodNotAllowedHandlers[g.prefix] = h
+	g.router.fire(xrouter.MethodNotAllowedHandlerEvent{Group: g.prefix})
+}
+
+// matchPattern matches a path against an httprouter pattern, returning the wildcard values.
+func matchPattern(pattern, path string) (httprouter.Params, bool) {
+	var params httprouter.Params
+	ps, segments := strings.Split(pattern, "/"), strings.Split(path, "/")
+	for i, p := range ps {
+		if strings.HasPrefix(p, "*") {
+			value := "/" + strings.Join(segments[min(i, len(segments)):], "/")
+			return append(params, httprouter.Param{Key: p[1:], Value: value}), true
+		}
+		if i >= len(segments) {
+			return nil, false
+		}
+		switch {
+		case strings.HasPrefix(p, ":") && segments[i] != "":
+			params = append(params, httprouter.Param{Key: p[1:], Value: segments[i]})
+		case p != segments[i]:
+			return nil, false
+		}
+	}
+	return params, len(ps) == len(segments)
+}
@@ -0,0 +1,70 @@
+package xroutertest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eliquious/xrouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeRouter(t *testing.T) {
+	f := NewFakeRouter()
+	f.Use(xrouter.LogHandler())
+	f.NotFound(http.NotFoundHandler())
+
+	api := f.Group("/api")
+	api.GET("/users/:id", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(xrouter.Param(ctx, "id")))
+	}, xrouter.Summary("Get a user"))
+	api.Group("/files").GET("/*path", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(xrouter.Param(ctx, "path")))
+	})
+
+	assert.Equal(t, 2, len(f.Registrations()))
+	assert.Equal(t, 3, len(f.Events))
+	assert.NotNil(t, f.NotFoundHandler)
+	assert.Equal(t, 1, len(api.(*FakeGroup).Middleware))
+	assert.Equal(t, "/api", api.Path())
+
+	reg, ok := f.Registered("GET", "/api/users/:id")
+	assert.True(t, ok)
+	assert.Equal(t, "Get a user", reg.Info.Summary)
+	assert.Equal(t, "/api", reg.Info.Group)
+
+	info, params, ok := f.Lookup("GET", "/api/files/a/b.txt")
+	assert.True(t, ok)
+	assert.Equal(t, "/api/files/*path", info.Path)
+	assert.Equal(t, "/a/b.txt", params.ByName("path"))
+
+	w := httptest.NewRecorder()
+	f.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/api/users/42", nil))
+	assert.Equal(t, "42", w.Body.String())
+
+	w = httptest.NewRecorder()
+	f.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/api/users", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestFakeGroupNotFound(t *testing.T) {
+	f := NewFakeRouter()
+	f.NotFound(http.NotFoundHandler())
+	api := f.Group("/api")
+	api.NotFound(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		xrouter.WriteError(w, http.StatusNotFound, "not found")
+	}))
+	api.MethodNotAllowed(http.NotFoundHandler())
+
+	assert.Contains(t, f.Events, xrouter.NotFoundHandlerEvent{Group: "/api"})
+	assert.NotNil(t, f.GroupMethodNotAllowedHandlers["/api"])
+
+	w := httptest.NewRecorder()
+	f.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/api/missing", nil))
+	assert.JSONEq(t, `{"status":404,"message":"not found"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	f.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/missing", nil))
+	assert.Equal(t, "404 page not found\n", w.Body.String())
+}
@@ -0,0 +1,356 @@
+// Package xroutertest provides utilities for testing services built on xrouter. Requests are served directly by the
+// router's handler using an httptest.ResponseRecorder, so no network listener is required.
+package xroutertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/eliquious/xrouter"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Client builds requests against a router.
+type Client struct {
+	t      testing.TB
+	router xrouter.Router
+	header http.Header
+}
+
+// New creates a client which serves requests with the given router and reports failures to t.
+func New(t testing.TB, r xrouter.Router) *Client {
+	return &Client{t, r, make(http.Header)}
+}
+
+// WithHeader sets a header which is sent with every request made by the client.
+func (c *Client) WithHeader(key, value string) *Client {
+	c.header.Set(key, value)
+	return c
+}
+
+// Request starts a request with the given method and path. The path may include a query string.
+func (c *Client) Request(method, path string) *Request {
+	header := make(http.Header)
+	for k, v := range c.header {
+		header[k] = append([]string(nil), v...)
+	}
+	return &Request{client: c, method: method, path: path, header: header, query: make(url.Values)}
+}
+
+// GET starts a GET request.
+func (c *Client) GET(path string) *Request {
+	return c.Request("GET", path)
+}
+
+// POST starts a POST request.
+func (c *Client) POST(path string) *Request {
+	return c.Request("POST", path)
+}
+
+// PUT starts a PUT request.
+func (c *Client) PUT(path string) *Request {
+	return c.Request("PUT", path)
+}
+
+// PATCH starts a PATCH request.
+func (c *Client) PATCH(path string) *Request {
+	return c.Request("PATCH", path)
+}
+
+// DELETE starts a DELETE request.
+func (c *Client) DELETE(path string) *Request {
+	return c.Request("DELETE", path)
+}
+
+// HEAD starts a HEAD request.
+func (c *Client) HEAD(path string) *Request {
+	return c.Request("HEAD", path)
+}
+
+// OPTIONS starts an OPTIONS request.
+func (c *Client) OPTIONS(path string) *Request {
+	return c.Request("OPTIONS", path)
+}
+
+// Request is a request being built by a Client.
+type Request struct {
+	client *Client
+	method string
+	path   string
+	header http.Header
+	query  url.Values
+	body   []byte
+}
+
+// Header sets a request header.
+func (r *Request) Header(key, value string) *Request {
+	r.header.Set(key, value)
+	return r
+}
+
+// Query adds a query string parameter.
+func (r *Request) Query(key, value string) *Request {
+	r.query.Add(key, value)
+	return r
+}
+
+// Body sets the raw request body.
+func (r *Request) Body(body string) *Request {
+	r.body = []byte(body)
+	return r
+}
+
+// JSON encodes v as the request body and sets the Content-Type header.
+func (r *Request) JSON(v interface{}) *Request {
+	body, err := json.Marshal(v)
+	if err != nil {
+		r.client.t.Fatalf("xroutertest: failed to encode JSON body: %v", err)
+	}
+	r.body = body
+	r.header.Set("Content-Type", "application/json")
+	return r
+}
+
+// Do serves the request and returns the recorded response.
+func (r *Request) Do() *Response {
+	r.client.t.Helper()
+
+	target := r.path
+	if len(r.query) > 0 {
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target += sep + r.query.Encode()
+	}
+
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+	req := httptest.NewRequest(r.method, target, body)
+	req.Header = r.header
+
+	// The route is recorded as it is served, since normalization and constraints can change which route handles the
+	// request.
+	var route xrouter.RouteInfo
+	var params httprouter.Params
+	var matched bool
+	req = req.WithContext(xrouter.ObserveRoute(req.Context(), func(info xrouter.RouteInfo, p httprouter.Params) {
+		if !matched {
+			route, params, matched = info, p, true
+		}
+	}))
+
+	w := httptest.NewRecorder()
+	r.client.router.Handler().ServeHTTP(w, req)
+	if matched {
+		record(r.client.router, route)
+	}
+	return &Response{ResponseRecorder: w, t: r.client.t, route: route, params: params, matched: matched}
+}
+
+// Response is a recorded response with chainable assertions. Failed assertions are reported with t.Errorf.
+type Response struct {
+	*httptest.ResponseRecorder
+	t       testing.TB
+	route   xrouter.RouteInfo
+	params  httprouter.Params
+	matched bool
+}
+
+// Status asserts the response status code.
+func (r *Response) Status(code int) *Response {
+	r.t.Helper()
+	if r.Code != code {
+		r.t.Errorf("xroutertest: expected status %d, got %d", code, r.Code)
+	}
+	return r
+}
+
+// Header asserts the value of a response header.
+func (r *Response) Header(key, value string) *Response {
+	r.t.Helper()
+	if got := r.ResponseRecorder.Header().Get(key); got != value {
+		r.t.Errorf("xroutertest: expected header %s to be %q, got %q", key, value, got)
+	}
+	return r
+}
+
+// BodyEquals asserts the response body.
+func (r *Response) BodyEquals(body string) *Response {
+	r.t.Helper()
+	if got := r.Body.String(); got != body {
+		r.t.Errorf("xroutertest: expected body %q, got %q", body, got)
+	}
+	return r
+}
+
+// BodyContains asserts that the response body contains the given string.
+func (r *Response) BodyContains(s string) *Response {
+	r.t.Helper()
+	if !strings.Contains(r.Body.String(), s) {
+		r.t.Errorf("xroutertest: expected body to contain %q, got %q", s, r.Body.String())
+	}
+	return r
+}
+
+// DecodeJSON decodes the response body into v.
+func (r *Response) DecodeJSON(v interface{}) *Response {
+	r.t.Helper()
+	if err := json.Unmarshal(r.Body.Bytes(), v); err != nil {
+		r.t.Errorf("xroutertest: failed to decode JSON body: %v", err)
+	}
+	return r
+}
+
+// JSONPath asserts the value found at a dot separated path in the JSON response body, such as "user.name" or
+// "items.0.id". Values are compared after converting want to its JSON representation.
+func (r *Response) JSONPath(path string, want interface{}) *Response {
+	r.t.Helper()
+
+	var body interface{}
+	if err := json.Unmarshal(r.Body.Bytes(), &body); err != nil {
+		r.t.Errorf("xroutertest: failed to decode JSON body: %v", err)
+		return r
+	}
+	got, err := lookupJSON(body, path)
+	if err != nil {
+		r.t.Errorf("xroutertest: %v", err)
+		return r
+	}
+
+	data, err := json.Marshal(want)
+	if err != nil {
+		r.t.Errorf("xroutertest: failed to encode expected value: %v", err)
+		return r
+	}
+	var expected interface{}
+	json.Unmarshal(data, &expected)
+	if !reflect.DeepEqual(expected, got) {
+		r.t.Errorf("xroutertest: expected %s to be %v, got %v", path, expected, got)
+	}
+	return r
+}
+
+// Route asserts the route which handled the request.
+func (r *Response) Route(method, path string) *Response {
+	r.t.Helper()
+	if !r.matched {
+		r.t.Errorf("xroutertest: expected route %s %s, but no route matched", method, path)
+	} else if r.route.Method != method || r.route.Path != path {
+		r.t.Errorf("xroutertest: expected route %s %s, got %s %s", method, path, r.route.Method, r.route.Path)
+	}
+	return r
+}
+
+// Param asserts the value of a URL parameter of the route which handled the request.
+func (r *Response) Param(name, value string) *Response {
+	r.t.Helper()
+	if got := r.params.ByName(name); got != value {
+		r.t.Errorf("xroutertest: expected param %s to be %q, got %q", name, value, got)
+	}
+	return r
+}
+
+// MatchedRoute returns the route which handled the request, if any.
+func (r *Response) MatchedRoute() (xrouter.RouteInfo, bool) {
+	return r.route, r.matched
+}
+
+// lookupJSON walks a decoded JSON value using a dot separated path.
+func lookupJSON(v interface{}, path string) (interface{}, error) {
+	if path == "" {
+		return v, nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			val, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf("path %s: key %q not found", path, key)
+			}
+			v = val
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("path %s: invalid index %q", path, key)
+			}
+			v = node[i]
+		default:
+			return nil, fmt.Errorf("path %s: cannot index %T with %q", path, v, key)
+		}
+	}
+	return v, nil
+}
+
+// coverage records the routes exercised for each router across all clients.
+var coverage = struct {
+	sync.Mutex
+	hits map[xrouter.Router]map[string]int
+}{hits: make(map[xrouter.Router]map[string]int)}
+
+func routeKey(method, path string) string {
+	return method + " " + path
+}
+
+func record(r xrouter.Router, route xrouter.RouteInfo) {
+	coverage.Lock()
+	defer coverage.Unlock()
+	hits, ok := coverage.hits[r]
+	if !ok {
+		hits = make(map[string]int)
+		coverage.hits[r] = hits
+	}
+	hits[routeKey(route.Method, route.Path)]++
+}
+
+// Uncovered returns the routes registered with the router which have not been requested by any Client.
+func Uncovered(r xrouter.Router) []xrouter.RouteInfo {
+	coverage.Lock()
+	defer coverage.Unlock()
+
+	var routes []xrouter.RouteInfo
+	for _, route := range r.Routes() {
+		if coverage.hits[r][routeKey(route.Method, route.Path)] == 0 {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// CoverageReport writes the request count of every registered route, followed by a summary. It is intended to be
+// called from TestMain after the tests have run.
+func CoverageReport(w io.Writer, r xrouter.Router) {
+	coverage.Lock()
+	defer coverage.Unlock()
+
+	routes := r.Routes()
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+
+	covered := 0
+	for _, route := range routes {
+		count := coverage.hits[r][routeKey(route.Method, route.Path)]
+		if count > 0 {
+			covered++
+		}
+		fmt.Fprintf(w, "%6d  %-7s %s\n", count, route.Method, route.Path)
+	}
+	fmt.Fprintf(w, "%d of %d routes covered\n", covered, len(routes))
+}
@@ -0,0 +1,123 @@
+package xroutertest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/eliquious/xrouter"
+	"github.com/stretchr/testify/assert"
+)
+
+type user struct {
+	ID   string   `json:"id"`
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+func newRouter() xrouter.Router {
+	r := xrouter.New()
+	api := r.Group("/api")
+	xrouter.JSON(api, "GET", "/users/:id", func(ctx context.Context, _ struct{}) (user, error) {
+		return user{xrouter.Param(ctx, "id"), "alice", []string{"admin", "ops"}}, nil
+	})
+	xrouter.JSON(api, "POST", "/users", func(ctx context.Context, u user) (user, error) {
+		return u, nil
+	})
+	api.GET("/echo", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Auth", r.Header.Get("Authorization"))
+		w.Write([]byte(r.URL.Query().Get("msg")))
+	})
+	api.DELETE("/users/:id", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {})
+	return r
+}
+
+// recorder captures assertion failures.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestClient(t *testing.T) {
+	r := newRouter()
+	c := New(t, r).WithHeader("Authorization", "Bearer token")
+
+	c.GET("/api/users/42").Do().
+		Status(http.StatusOK).
+		Header("Content-Type", "application/json; charset=utf-8").
+		Route("GET", "/api/users/:id").
+		Param("id", "42").
+		JSONPath("name", "alice").
+		JSONPath("tags.1", "ops")
+
+	c.POST("/api/users").JSON(user{ID: "1", Name: "bob"}).Do().
+		Status(http.StatusCreated).
+		JSONPath("", map[string]interface{}{"id": "1", "name": "bob", "tags": nil})
+
+	c.GET("/api/echo").Query("msg", "hello").Do().
+		Status(http.StatusOK).
+		Header("X-Auth", "Bearer token").
+		BodyEquals("hello")
+}
+
+func TestClientFailures(t *testing.T) {
+	rec := &recorder{TB: t}
+	c := New(rec, newRouter())
+
+	c.GET("/api/users/42").Do().
+		Status(http.StatusNotFound).
+		Route("GET", "/api/users").
+		Param("id", "7").
+		JSONPath("name", "bob").
+		JSONPath("tags.5", "x")
+	assert.Equal(t, 5, len(rec.errors))
+
+	rec.errors = nil
+	c.GET("/missing").Do().Status(http.StatusNotFound).Route("GET", "/missing")
+	assert.Equal(t, 1, len(rec.errors))
+	assert.Contains(t, rec.errors[0], "no route matched")
+}
+
+func TestCoverage(t *testing.T) {
+	r := newRouter()
+	c := New(t, r)
+	c.GET("/api/users/1").Do()
+	c.GET("/api/users/2").Do()
+	c.GET("/api/echo").Do()
+
+	uncovered := Uncovered(r)
+	assert.Equal(t, 2, len(uncovered))
+	assert.Equal(t, "POST", uncovered[0].Method)
+	assert.Equal(t, "DELETE", uncovered[1].Method)
+
+	var buf bytes.Buffer
+	CoverageReport(&buf, r)
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	assert.Equal(t, 5, len(lines))
+	assert.Equal(t, "     1  GET     /api/echo", lines[0])
+	assert.Equal(t, "     2  GET     /api/users/:id", lines[3])
+	assert.Equal(t, "2 of 4 routes covered", lines[4])
+}
+
+func TestCoverageNormalizedPaths(t *testing.T) {
+	r := xrouter.New(xrouter.NormalizePaths(xrouter.PathNormalization{CollapseSlashes: true, StripTrailingSlash: true}))
+	r.GET("/users/:id<int>", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {})
+	r.GET("/teams", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {})
+	c := New(t, r)
+
+	c.GET("//users/1/").Do().Status(http.StatusOK).Route("GET", "/users/:id").Param("id", "1")
+	assert.Equal(t, []xrouter.RouteInfo{r.Routes()[1]}, Uncovered(r))
+
+	// A request rejected by a constraint is not attributed to the route.
+	_, matched := c.GET("/users/abc").Do().Status(http.StatusNotFound).MatchedRoute()
+	assert.False(t, matched)
+}